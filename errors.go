@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+const PROBLEM_CONTENT_TYPE = "application/problem+json"
+
+// Machine-readable error codes. Clients branch on these, so they must not
+// change once released.
+const (
+	CODE_INVALID_JSON          = "invalid_json"
+	CODE_INVALID_PARAMETER     = "invalid_parameter"
+	CODE_UNAUTHORIZED          = "unauthorized"
+	CODE_INVALID_CREDENTIALS   = "invalid_credentials"
+	CODE_INVALID_TOKEN         = "invalid_token"
+	CODE_INVALID_API_KEY       = "invalid_api_key"
+	CODE_FORBIDDEN             = "forbidden"
+	CODE_NOT_FOUND             = "not_found"
+	CODE_ALREADY_EXISTS        = "already_exists"
+	CODE_EMPTY_CHIRP           = "empty_chirp"
+	CODE_CHIRP_TOO_LONG        = "chirp_too_long"
+	CODE_PROFANITY             = "profanity"
+	CODE_PASSWORD_TOO_SHORT    = "password_too_short"
+	CODE_TIMEOUT               = "timeout"
+	CODE_CLIENT_CLOSED_REQUEST = "client_closed_request"
+	CODE_INTERNAL              = "internal_error"
+)
+
+const MIN_PASSWORD_LENGTH = 5
+
+// apiError is an error response. Message and Details are sent to the client;
+// Err is the underlying cause and is only ever logged.
+type apiError struct {
+	Status  int
+	Code    string
+	Message string
+	Details map[string]any
+	Err     error
+}
+
+func newAPIError(status int, code, message string) *apiError {
+	return &apiError{Status: status, Code: code, Message: message}
+}
+
+func (e *apiError) Error() string {
+	if e.Err != nil {
+		return e.Code + ": " + e.Err.Error()
+	}
+	return e.Code + ": " + e.Message
+}
+
+func (e *apiError) Unwrap() error {
+	return e.Err
+}
+
+func (e *apiError) withDetails(details map[string]any) *apiError {
+	e.Details = details
+	return e
+}
+
+func errInvalidJSON() *apiError {
+	return newAPIError(http.StatusBadRequest, CODE_INVALID_JSON, "Request body is not valid JSON")
+}
+
+func errInvalidParameter(name, message string) *apiError {
+	return newAPIError(http.StatusBadRequest, CODE_INVALID_PARAMETER, message).
+		withDetails(map[string]any{"parameter": name})
+}
+
+func errUnauthorized() *apiError {
+	return newAPIError(http.StatusUnauthorized, CODE_UNAUTHORIZED, "Unauthorized")
+}
+
+func errNotFound(message string) *apiError {
+	return newAPIError(http.StatusNotFound, CODE_NOT_FOUND, message)
+}
+
+func errChirpTooLong(maxLength int) *apiError {
+	return newAPIError(http.StatusBadRequest, CODE_CHIRP_TOO_LONG, "Chirp is too long").
+		withDetails(map[string]any{"max_length": maxLength})
+}
+
+func errProfanity(words []string) *apiError {
+	return newAPIError(http.StatusUnprocessableEntity, CODE_PROFANITY, "Chirp contains banned words").
+		withDetails(map[string]any{"words": words})
+}
+
+// errInternal hides err from the client behind a generic 500.
+func errInternal(err error) *apiError {
+	return &apiError{
+		Status:  http.StatusInternalServerError,
+		Code:    CODE_INTERNAL,
+		Message: "Internal server error",
+		Err:     err,
+	}
+}
+
+// errDatabase reports a failed query with the status from dbErrorStatus.
+func errDatabase(ctx context.Context, err error) *apiError {
+	switch dbErrorStatus(ctx, err) {
+	case http.StatusServiceUnavailable:
+		return &apiError{
+			Status:  http.StatusServiceUnavailable,
+			Code:    CODE_TIMEOUT,
+			Message: "The request took too long, try again later",
+			Err:     err,
+		}
+	case STATUS_CLIENT_CLOSED_REQUEST:
+		return &apiError{
+			Status:  STATUS_CLIENT_CLOSED_REQUEST,
+			Code:    CODE_CLIENT_CLOSED_REQUEST,
+			Message: "Client closed request",
+			Err:     err,
+		}
+	default:
+		return errInternal(err)
+	}
+}
+
+// problem is an RFC 7807 problem details body. The type is always about:blank,
+// so the title is the status text. It is extended with the error code, the
+// details and the request ID so a report can be matched to the server logs.
+type problem struct {
+	Type      string         `json:"type"`
+	Title     string         `json:"title"`
+	Status    int            `json:"status"`
+	Detail    string         `json:"detail,omitempty"`
+	Instance  string         `json:"instance,omitempty"`
+	Code      string         `json:"code"`
+	Details   map[string]any `json:"details,omitempty"`
+	RequestID string         `json:"request_id,omitempty"`
+}
+
+// respondWithError writes err as application/problem+json. Errors that are
+// not an *apiError are treated as internal. The cause of an error is logged
+// and never included in the response.
+func respondWithError(w http.ResponseWriter, r *http.Request, err error) {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		apiErr = errInternal(err)
+	}
+
+	if apiErr.Err != nil {
+		level := slog.LevelError
+		if apiErr.Status < http.StatusInternalServerError {
+			level = slog.LevelWarn
+		}
+		slog.Log(r.Context(), level, "Request failed", "status", apiErr.Status, "code", apiErr.Code, "error", apiErr.Err)
+	}
+
+	title := http.StatusText(apiErr.Status)
+	if apiErr.Status == STATUS_CLIENT_CLOSED_REQUEST {
+		title = "Client Closed Request"
+	}
+
+	body, marshalErr := json.Marshal(problem{
+		Type:      "about:blank",
+		Title:     title,
+		Status:    apiErr.Status,
+		Detail:    apiErr.Message,
+		Instance:  r.URL.Path,
+		Code:      apiErr.Code,
+		Details:   apiErr.Details,
+		RequestID: requestIDFromContext(r.Context()),
+	})
+	if marshalErr != nil {
+		slog.ErrorContext(r.Context(), "Error marshalling problem", "error", marshalErr)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", PROBLEM_CONTENT_TYPE)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(apiErr.Status)
+	w.Write(body)
+}
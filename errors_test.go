@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRespondWithError(t *testing.T) {
+	expired, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+
+	secret := errors.New(`pq: duplicate key value violates unique constraint "users_email_key"`)
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantDetail string
+	}{
+		{name: "API error", err: errChirpTooLong(140), wantStatus: http.StatusBadRequest, wantCode: CODE_CHIRP_TOO_LONG, wantDetail: "Chirp is too long"},
+		{name: "Internal error", err: errInternal(secret), wantStatus: http.StatusInternalServerError, wantCode: CODE_INTERNAL, wantDetail: "Internal server error"},
+		{name: "Plain error", err: secret, wantStatus: http.StatusInternalServerError, wantCode: CODE_INTERNAL, wantDetail: "Internal server error"},
+		{name: "Database timeout", err: errDatabase(expired, secret), wantStatus: http.StatusServiceUnavailable, wantCode: CODE_TIMEOUT},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := middlewareLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				respondWithError(w, r, tt.err)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/chirps", nil)
+			req.Header.Set(REQUEST_ID_HEADER, "abc-123")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Type"); got != PROBLEM_CONTENT_TYPE {
+				t.Errorf("Content-Type = %q, want %q", got, PROBLEM_CONTENT_TYPE)
+			}
+			if strings.Contains(rec.Body.String(), "pq:") {
+				t.Errorf("response leaks the internal error: %s", rec.Body.String())
+			}
+
+			var got problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			if got.Status != tt.wantStatus || got.Code != tt.wantCode {
+				t.Errorf("problem = %d %q, want %d %q", got.Status, got.Code, tt.wantStatus, tt.wantCode)
+			}
+			if tt.wantDetail != "" && got.Detail != tt.wantDetail {
+				t.Errorf("detail = %q, want %q", got.Detail, tt.wantDetail)
+			}
+			if got.Title != http.StatusText(tt.wantStatus) || got.Type != "about:blank" {
+				t.Errorf("type, title = %q, %q", got.Type, got.Title)
+			}
+			if got.RequestID != "abc-123" || got.Instance != "/api/chirps" {
+				t.Errorf("request_id, instance = %q, %q", got.RequestID, got.Instance)
+			}
+		})
+	}
+}
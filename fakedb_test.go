@@ -0,0 +1,550 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SzymonJaroslawski/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// fakeDB is an in-memory stand-in for postgres that handlers can run against
+// through database/sql. Queries are told apart by the name sqlc puts in their
+// first line and emulated in Go, so a test that reaches a query the fake
+// doesn't know yet fails instead of silently passing.
+type fakeDB struct {
+	mu        sync.Mutex
+	users     []database.User
+	chirps    []database.Chirp
+	revisions []database.ChirpRevision
+	likes     []database.ChirpLike
+	follows   []database.Follow
+}
+
+// fakeQuery runs one query against the tables. It returns the rows a query
+// selects or returns, or the number of rows a statement affected.
+type fakeQuery func(db *fakeDB, args []any) (rows [][]driver.Value, affected int64, err error)
+
+var fakeQueries = map[string]fakeQuery{
+	"AdjustChirpLikeCount": func(db *fakeDB, args []any) ([][]driver.Value, int64, error) {
+		chirp := db.chirp(args[1].(uuid.UUID))
+		if chirp == nil {
+			return nil, 0, nil
+		}
+		chirp.LikeCount += args[0].(int32)
+		return [][]driver.Value{chirpValues(*chirp)}, 1, nil
+	},
+	"CreateChirpLike": func(db *fakeDB, args []any) ([][]driver.Value, int64, error) {
+		chirpID, userID := args[0].(uuid.UUID), args[1].(uuid.UUID)
+		if db.activeUser(userID) == nil || slices.ContainsFunc(db.likes, func(l database.ChirpLike) bool {
+			return l.ChirpID == chirpID && l.UserID == userID
+		}) {
+			return nil, 0, nil
+		}
+		db.likes = append(db.likes, database.ChirpLike{ChirpID: chirpID, UserID: userID, CreatedAt: time.Now().UTC()})
+		return nil, 1, nil
+	},
+	"CreateChirpRevision": func(db *fakeDB, args []any) ([][]driver.Value, int64, error) {
+		db.revisions = append(db.revisions, database.ChirpRevision{
+			ID:        uuid.New(),
+			CreatedAt: time.Now().UTC(),
+			ChirpID:   args[0].(uuid.UUID),
+			Body:      args[1].(string),
+		})
+		return nil, 1, nil
+	},
+	"CreateFollow": func(db *fakeDB, args []any) ([][]driver.Value, int64, error) {
+		followerID, followeeID := args[0].(uuid.UUID), args[1].(uuid.UUID)
+		if db.activeUser(followerID) == nil || slices.ContainsFunc(db.follows, func(f database.Follow) bool {
+			return f.FollowerID == followerID && f.FolloweeID == followeeID
+		}) {
+			return nil, 0, nil
+		}
+		db.follows = append(db.follows, database.Follow{FollowerID: followerID, FolloweeID: followeeID, CreatedAt: time.Now().UTC()})
+		return nil, 1, nil
+	},
+	"DeleteChirpLike": func(db *fakeDB, args []any) ([][]driver.Value, int64, error) {
+		before := len(db.likes)
+		db.likes = slices.DeleteFunc(db.likes, func(l database.ChirpLike) bool {
+			return l.ChirpID == args[0].(uuid.UUID) && l.UserID == args[1].(uuid.UUID)
+		})
+		return nil, int64(before - len(db.likes)), nil
+	},
+	// The fake keeps no mentions, so there is nothing to delete.
+	"DeleteChirpMentions": func(db *fakeDB, args []any) ([][]driver.Value, int64, error) {
+		return nil, 0, nil
+	},
+	"DeleteFollow": func(db *fakeDB, args []any) ([][]driver.Value, int64, error) {
+		before := len(db.follows)
+		db.follows = slices.DeleteFunc(db.follows, func(f database.Follow) bool {
+			return f.FollowerID == args[0].(uuid.UUID) && f.FolloweeID == args[1].(uuid.UUID)
+		})
+		return nil, int64(before - len(db.follows)), nil
+	},
+	"DeleteUser": func(db *fakeDB, args []any) ([][]driver.Value, int64, error) {
+		id := args[0].(uuid.UUID)
+		i := slices.IndexFunc(db.users, func(u database.User) bool { return u.ID == id && !u.DeletedAt.Valid })
+		if i < 0 {
+			return nil, 0, nil
+		}
+		db.users = slices.Delete(db.users, i, i+1)
+
+		// ON DELETE CASCADE
+		var chirpIDs []uuid.UUID
+		db.chirps = slices.DeleteFunc(db.chirps, func(c database.Chirp) bool {
+			if c.UserID == id {
+				chirpIDs = append(chirpIDs, c.ID)
+				return true
+			}
+			return false
+		})
+		db.revisions = slices.DeleteFunc(db.revisions, func(r database.ChirpRevision) bool {
+			return slices.Contains(chirpIDs, r.ChirpID)
+		})
+		db.likes = slices.DeleteFunc(db.likes, func(l database.ChirpLike) bool {
+			return l.UserID == id || slices.Contains(chirpIDs, l.ChirpID)
+		})
+		db.follows = slices.DeleteFunc(db.follows, func(f database.Follow) bool {
+			return f.FollowerID == id || f.FolloweeID == id
+		})
+		return nil, 1, nil
+	},
+	"GetChirpForUpdate": getLiveChirp,
+	"GetChirpWithId":    getLiveChirp,
+	"GetUserForUpdate":  getActiveUser,
+	"GetUserWithId":     getActiveUser,
+	"ListFollowers": func(db *fakeDB, args []any) ([][]driver.Value, int64, error) {
+		return db.listFollows(args, func(f database.Follow) (uuid.UUID, uuid.UUID) { return f.FolloweeID, f.FollowerID }), 0, nil
+	},
+	"ListFollowing": func(db *fakeDB, args []any) ([][]driver.Value, int64, error) {
+		return db.listFollows(args, func(f database.Follow) (uuid.UUID, uuid.UUID) { return f.FollowerID, f.FolloweeID }), 0, nil
+	},
+	"ListLikedChirpIDs": func(db *fakeDB, args []any) ([][]driver.Value, int64, error) {
+		ids := args[1].(pq.GenericArray).A.([]uuid.UUID)
+		var rows [][]driver.Value
+		for _, like := range db.likes {
+			if like.UserID == args[0].(uuid.UUID) && slices.Contains(ids, like.ChirpID) {
+				rows = append(rows, driverValues(like.ChirpID))
+			}
+		}
+		return rows, 0, nil
+	},
+	"ListTimeline": func(db *fakeDB, args []any) ([][]driver.Value, int64, error) {
+		cursorCreatedAt, cursorID, limit, userID := args[0].(sql.NullTime), args[1].(uuid.NullUUID), args[2].(int32), args[3].(uuid.UUID)
+
+		var chirps []database.Chirp
+		for _, chirp := range db.chirps {
+			followed := slices.ContainsFunc(db.follows, func(f database.Follow) bool {
+				return f.FollowerID == userID && f.FolloweeID == chirp.UserID
+			})
+			if followed && !chirp.DeletedAt.Valid && beforeCursor(chirp.CreatedAt, chirp.ID, cursorCreatedAt, cursorID) {
+				chirps = append(chirps, chirp)
+			}
+		}
+		slices.SortFunc(chirps, func(a, b database.Chirp) int { return compareKeysDesc(a.CreatedAt, a.ID, b.CreatedAt, b.ID) })
+
+		var rows [][]driver.Value
+		for _, chirp := range chirps[:min(len(chirps), int(limit))] {
+			rows = append(rows, chirpValues(chirp))
+		}
+		return rows, 0, nil
+	},
+	"SubtractUserLikes": func(db *fakeDB, args []any) ([][]driver.Value, int64, error) {
+		var affected int64
+		for _, like := range db.likes {
+			if like.UserID != args[0].(uuid.UUID) {
+				continue
+			}
+			if chirp := db.chirp(like.ChirpID); chirp != nil {
+				chirp.LikeCount--
+				affected++
+			}
+		}
+		return nil, affected, nil
+	},
+	"UpdateChirpBody": func(db *fakeDB, args []any) ([][]driver.Value, int64, error) {
+		chirp := db.chirp(args[0].(uuid.UUID))
+		if chirp == nil {
+			return nil, 0, nil
+		}
+		now := time.Now().UTC()
+		chirp.Body = args[1].(string)
+		chirp.Flagged = args[2].(bool)
+		chirp.UpdatedAt = now
+		chirp.EditedAt = sql.NullTime{Time: now, Valid: true}
+		return [][]driver.Value{chirpValues(*chirp)}, 1, nil
+	},
+	"UserIsActive": func(db *fakeDB, args []any) ([][]driver.Value, int64, error) {
+		active := db.activeUser(args[0].(uuid.UUID)) != nil
+		return [][]driver.Value{{active}}, 0, nil
+	},
+}
+
+func getActiveUser(db *fakeDB, args []any) ([][]driver.Value, int64, error) {
+	user := db.activeUser(args[0].(uuid.UUID))
+	if user == nil {
+		return nil, 0, nil
+	}
+	return [][]driver.Value{userValues(*user)}, 0, nil
+}
+
+func getLiveChirp(db *fakeDB, args []any) ([][]driver.Value, int64, error) {
+	chirp := db.chirp(args[0].(uuid.UUID))
+	if chirp == nil || chirp.DeletedAt.Valid {
+		return nil, 0, nil
+	}
+	return [][]driver.Value{chirpValues(*chirp)}, 0, nil
+}
+
+// listFollows is ListFollowers and ListFollowing. ends picks the user the list
+// is for and the user it lists out of a follow.
+func (db *fakeDB) listFollows(args []any, ends func(database.Follow) (uuid.UUID, uuid.UUID)) [][]driver.Value {
+	userID, cursorCreatedAt, cursorID, limit := args[0].(uuid.UUID), args[1].(sql.NullTime), args[2].(uuid.NullUUID), args[3].(int32)
+
+	var follows []database.Follow
+	for _, follow := range db.follows {
+		of, listed := ends(follow)
+		if of == userID && db.activeUser(listed) != nil && beforeCursor(follow.CreatedAt, listed, cursorCreatedAt, cursorID) {
+			follows = append(follows, follow)
+		}
+	}
+	slices.SortFunc(follows, func(a, b database.Follow) int {
+		_, aID := ends(a)
+		_, bID := ends(b)
+		return compareKeysDesc(a.CreatedAt, aID, b.CreatedAt, bID)
+	})
+
+	var rows [][]driver.Value
+	for _, follow := range follows[:min(len(follows), int(limit))] {
+		_, listed := ends(follow)
+		user := db.activeUser(listed)
+		rows = append(rows, driverValues(user.ID, user.Handle, user.DisplayName, user.AvatarUrl, follow.CreatedAt))
+	}
+	return rows
+}
+
+// beforeCursor is the (created_at, id) < (cursor_created_at, cursor_id) of the
+// list queries. Every row is before a NULL cursor.
+func beforeCursor(createdAt time.Time, id uuid.UUID, cursorCreatedAt sql.NullTime, cursorID uuid.NullUUID) bool {
+	if !cursorCreatedAt.Valid {
+		return true
+	}
+	return compareKeysDesc(createdAt, id, cursorCreatedAt.Time, cursorID.UUID) > 0
+}
+
+// compareKeysDesc orders (created_at, id) keys newest first, comparing IDs
+// byte by byte like postgres does.
+func compareKeysDesc(aCreatedAt time.Time, aID uuid.UUID, bCreatedAt time.Time, bID uuid.UUID) int {
+	if c := bCreatedAt.Compare(aCreatedAt); c != 0 {
+		return c
+	}
+	return bytes.Compare(bID[:], aID[:])
+}
+
+// newFakeDB points cfg at an empty fakeDB and returns it for the test to fill
+// and inspect.
+func newFakeDB(t *testing.T, cfg *apiConfig) *fakeDB {
+	t.Helper()
+
+	db := &fakeDB{}
+	conn := sql.OpenDB(fakeConnector{db: db})
+	t.Cleanup(func() { conn.Close() })
+
+	cfg.dbConn = conn
+	cfg.db = database.New(instrumentedDB{db: conn, metrics: cfg.metrics})
+	if cfg.dbTimeout == 0 {
+		cfg.dbTimeout = 5 * time.Second
+	}
+	return db
+}
+
+func (db *fakeDB) addUser(user database.User) database.User {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if user.ID == uuid.Nil {
+		user.ID = uuid.New()
+	}
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now().UTC()
+		user.UpdatedAt = user.CreatedAt
+	}
+	db.users = append(db.users, user)
+	return user
+}
+
+func (db *fakeDB) addLike(chirpID, userID uuid.UUID) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.likes = append(db.likes, database.ChirpLike{ChirpID: chirpID, UserID: userID, CreatedAt: time.Now().UTC()})
+	db.chirp(chirpID).LikeCount++
+}
+
+func (db *fakeDB) addChirp(chirp database.Chirp) database.Chirp {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if chirp.ID == uuid.Nil {
+		chirp.ID = uuid.New()
+	}
+	if chirp.CreatedAt.IsZero() {
+		chirp.CreatedAt = time.Now().UTC()
+		chirp.UpdatedAt = chirp.CreatedAt
+	}
+	db.chirps = append(db.chirps, chirp)
+	return chirp
+}
+
+func (db *fakeDB) addFollow(followerID, followeeID uuid.UUID) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.follows = append(db.follows, database.Follow{FollowerID: followerID, FolloweeID: followeeID, CreatedAt: time.Now().UTC()})
+}
+
+// likeCount counts the chirp_likes rows of a chirp, to hold like_count
+// against.
+func (db *fakeDB) likeCount(chirpID uuid.UUID) int32 {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var count int32
+	for _, like := range db.likes {
+		if like.ChirpID == chirpID {
+			count++
+		}
+	}
+	return count
+}
+
+// revisionBodies returns the saved bodies of a chirp, oldest first.
+func (db *fakeDB) revisionBodies(chirpID uuid.UUID) []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var bodies []string
+	for _, revision := range db.revisions {
+		if revision.ChirpID == chirpID {
+			bodies = append(bodies, revision.Body)
+		}
+	}
+	return bodies
+}
+
+// getChirp returns a copy of the chirp with the given ID, deleted or not.
+func (db *fakeDB) getChirp(id uuid.UUID) (database.Chirp, bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	chirp := db.chirp(id)
+	if chirp == nil {
+		return database.Chirp{}, false
+	}
+	return *chirp, true
+}
+
+// hasUser reports whether a row for the user exists, deleted or not.
+func (db *fakeDB) hasUser(id uuid.UUID) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return slices.ContainsFunc(db.users, func(u database.User) bool { return u.ID == id })
+}
+
+func (db *fakeDB) activeUser(id uuid.UUID) *database.User {
+	for i := range db.users {
+		if db.users[i].ID == id && !db.users[i].DeletedAt.Valid {
+			return &db.users[i]
+		}
+	}
+	return nil
+}
+
+func (db *fakeDB) chirp(id uuid.UUID) *database.Chirp {
+	for i := range db.chirps {
+		if db.chirps[i].ID == id {
+			return &db.chirps[i]
+		}
+	}
+	return nil
+}
+
+func (db *fakeDB) run(query string, args []driver.NamedValue) ([][]driver.Value, int64, error) {
+	match := sqlcQueryName.FindStringSubmatch(query)
+	if match == nil {
+		return nil, 0, fmt.Errorf("fakedb: not a sqlc query: %q", query)
+	}
+	run, ok := fakeQueries[match[1]]
+	if !ok {
+		return nil, 0, fmt.Errorf("fakedb: %s is not implemented", match[1])
+	}
+
+	values := make([]any, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return run(db, values)
+}
+
+// snapshot copies the tables so a rolled back transaction can put them back.
+// Transactions are not isolated from each other; tests run one at a time.
+func (db *fakeDB) snapshot() *fakeDB {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return &fakeDB{
+		users:     slices.Clone(db.users),
+		chirps:    slices.Clone(db.chirps),
+		revisions: slices.Clone(db.revisions),
+		likes:     slices.Clone(db.likes),
+		follows:   slices.Clone(db.follows),
+	}
+}
+
+func (db *fakeDB) restore(snapshot *fakeDB) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.users = snapshot.users
+	db.chirps = snapshot.chirps
+	db.revisions = snapshot.revisions
+	db.likes = snapshot.likes
+	db.follows = snapshot.follows
+}
+
+func userValues(u database.User) []driver.Value {
+	return driverValues(u.ID, u.CreatedAt, u.UpdatedAt, u.Email, u.HashedPassowrd, u.IsChirpyRed,
+		u.DeletedAt, u.Handle, u.DisplayName, u.Bio, u.AvatarUrl)
+}
+
+func chirpValues(c database.Chirp) []driver.Value {
+	return driverValues(c.ID, c.CreatedAt, c.UpdatedAt, c.Body, c.UserID, c.Flagged, c.EditedAt, c.DeletedAt, c.LikeCount)
+}
+
+// driverValues converts Go values to the few types a driver may return.
+func driverValues(values ...any) []driver.Value {
+	out := make([]driver.Value, len(values))
+	for i, v := range values {
+		if valuer, ok := v.(driver.Valuer); ok {
+			v, _ = valuer.Value()
+		}
+		if n, ok := v.(int32); ok {
+			v = int64(n)
+		}
+		out[i] = v
+	}
+	return out
+}
+
+type fakeConnector struct {
+	db *fakeDB
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{db: c.db}, nil
+}
+
+func (c fakeConnector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fakedb: open with sql.OpenDB")
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fakedb: prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return &fakeTx{db: c.db, snapshot: c.db.snapshot()}, nil
+}
+
+// CheckNamedValue hands arguments to the queries as the Go values the sqlc
+// code passed in.
+func (c *fakeConn) CheckNamedValue(*driver.NamedValue) error {
+	return nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	_, affected, err := c.db.run(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(affected), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, _, err := c.db.run(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{rows: rows}, nil
+}
+
+type fakeTx struct {
+	db       *fakeDB
+	snapshot *fakeDB
+}
+
+func (tx *fakeTx) Commit() error {
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.db.restore(tx.snapshot)
+	return nil
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+	next int
+}
+
+func (r *fakeRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return nil
+	}
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
@@ -4,20 +4,43 @@ go 1.23.4
 
 require (
 	github.com/SzymonJaroslawski/chirpy/internal/auth v0.0.0
+	github.com/SzymonJaroslawski/chirpy/internal/config v0.0.0
 	github.com/SzymonJaroslawski/chirpy/internal/database v0.0.0
+	github.com/SzymonJaroslawski/chirpy/internal/filter v0.0.0
 	github.com/lib/pq v1.10.9
 )
 
 require (
 	github.com/google/uuid v1.6.0
 	github.com/joho/godotenv v1.5.1
+	github.com/pressly/goose/v3 v3.24.3
+	github.com/prometheus/client_golang v1.20.5
 )
 
 require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/golang-jwt/jwt/v5 v5.2.1 // indirect
-	golang.org/x/crypto v0.30.0 // indirect
+	github.com/klauspost/compress v1.18.0 // indirect
+	github.com/mfridman/interpolate v0.0.2 // indirect
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/prometheus/client_model v0.6.1 // indirect
+	github.com/prometheus/common v0.55.0 // indirect
+	github.com/prometheus/procfs v0.16.1 // indirect
+	github.com/sethvargo/go-retry v0.3.0 // indirect
+	go.uber.org/multierr v1.11.0 // indirect
+	golang.org/x/crypto v0.38.0 // indirect
+	golang.org/x/sync v0.14.0 // indirect
+	golang.org/x/sys v0.33.0 // indirect
+	golang.org/x/text v0.25.0 // indirect
+	google.golang.org/protobuf v1.36.6 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
 replace github.com/SzymonJaroslawski/chirpy/internal/database v0.0.0 => ./internal/database/
 
 replace github.com/SzymonJaroslawski/chirpy/internal/auth v0.0.0 => ./internal/auth/
+
+replace github.com/SzymonJaroslawski/chirpy/internal/config v0.0.0 => ./internal/config/
+
+replace github.com/SzymonJaroslawski/chirpy/internal/filter v0.0.0 => ./internal/filter/
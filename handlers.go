@@ -2,26 +2,51 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"html/template"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/SzymonJaroslawski/chirpy/internal/auth"
 	"github.com/SzymonJaroslawski/chirpy/internal/database"
 	"github.com/google/uuid"
 )
 
-var PROFANE = []string{"kerfuffle", "sharbert", "fornax"}
-
 type Chirp struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	Body      string    `json:"body"`
 	Id        uuid.UUID `json:"id"`
 	UserID    uuid.UUID `json:"user_id"`
+	Edited    bool      `json:"edited"`
+	LikeCount int32     `json:"like_count"`
+	LikedByMe bool      `json:"liked_by_me"`
+}
+
+func chirpFromDB(chirp database.Chirp) Chirp {
+	return Chirp{
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body:      chirp.Body,
+		Id:        chirp.ID,
+		UserID:    chirp.UserID,
+		Edited:    chirp.EditedAt.Valid,
+		LikeCount: chirp.LikeCount,
+	}
+}
+
+type ChirpRevision struct {
+	CreatedAt time.Time `json:"created_at"`
+	Body      string    `json:"body"`
+	Id        uuid.UUID `json:"id"`
 }
 
 type User struct {
@@ -31,241 +56,1495 @@ type User struct {
 	Token        string    `json:"token"`
 	RefreshToken string    `json:"refresh_token"`
 	Id           uuid.UUID `json:"id"`
+	IsChirpyRed  bool      `json:"is_chirpy_red"`
+}
+
+// UserProfile is the part of a user anyone can see. Unset fields are null.
+type UserProfile struct {
+	CreatedAt   time.Time `json:"created_at"`
+	Handle      *string   `json:"handle"`
+	DisplayName *string   `json:"display_name"`
+	Bio         *string   `json:"bio"`
+	AvatarURL   *string   `json:"avatar_url"`
+	Id          uuid.UUID `json:"id"`
+	ChirpCount  int64     `json:"chirp_count"`
+}
+
+func profileFromDB(user database.User, chirpCount int64) UserProfile {
+	return UserProfile{
+		CreatedAt:   user.CreatedAt,
+		Handle:      nullStringPtr(user.Handle),
+		DisplayName: nullStringPtr(user.DisplayName),
+		Bio:         nullStringPtr(user.Bio),
+		AvatarURL:   nullStringPtr(user.AvatarUrl),
+		Id:          user.ID,
+		ChirpCount:  chirpCount,
+	}
+}
+
+// OwnProfile is what users see of themselves.
+type OwnProfile struct {
+	UserProfile
+	UpdatedAt   time.Time `json:"updated_at"`
+	Email       string    `json:"email"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+}
+
+func ownProfileFromDB(user database.User, chirpCount int64) OwnProfile {
+	return OwnProfile{
+		UserProfile: profileFromDB(user, chirpCount),
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		IsChirpyRed: user.IsChirpyRed,
+	}
+}
+
+// FollowUser is an entry in a follower or following list.
+type FollowUser struct {
+	Handle      *string   `json:"handle"`
+	DisplayName *string   `json:"display_name"`
+	AvatarURL   *string   `json:"avatar_url"`
+	FollowedAt  time.Time `json:"followed_at"`
+	Id          uuid.UUID `json:"id"`
+}
+
+// followRow is a row of the follower or following list. Both queries return
+// the same columns, so their rows convert into each other.
+type followRow interface {
+	database.ListFollowersRow | database.ListFollowingRow
+}
+
+func followUserFromDB[Row followRow](followRow Row) FollowUser {
+	row := database.ListFollowersRow(followRow)
+	return FollowUser{
+		Handle:      nullStringPtr(row.Handle),
+		DisplayName: nullStringPtr(row.DisplayName),
+		AvatarURL:   nullStringPtr(row.AvatarUrl),
+		FollowedAt:  row.FollowedAt,
+		Id:          row.ID,
+	}
+}
+
+type BannedWord struct {
+	CreatedAt time.Time `json:"created_at"`
+	Word      string    `json:"word"`
+	Id        uuid.UUID `json:"id"`
+}
+
+func handlePutUsers(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	type Parameters struct {
+		Password string `json:"password"`
+		Email    string `json:"email"`
+	}
+
+	tokenId, ok := userIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, r, errUnauthorized())
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := Parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, errInvalidJSON())
+		return
+	}
+
+	newHashedPasswd, err := auth.HashedPassword(params.Password)
+	if err != nil {
+		respondWithError(w, r, errInternal(fmt.Errorf("hashing password: %w", err)))
+		return
+	}
+
+	user, err := cfg.db.UpdateUserEmailAndPassword(ctx, database.UpdateUserEmailAndPasswordParams{
+		Email:          params.Email,
+		HashedPassowrd: newHashedPasswd,
+		ID:             tokenId,
+	})
+	// The account was deleted after the token was issued.
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, errUnauthorized())
+		return
+	}
+	if isUniqueViolation(err, "users_email_key") {
+		respondWithError(w, r, newAPIError(http.StatusConflict, CODE_ALREADY_EXISTS, "Email is already taken"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("updating user %s: %w", tokenId, err)))
+		return
+	}
+
+	type Response struct {
+		Email string `json:"email"`
+	}
+
+	res := Response{
+		Email: user.Email,
+	}
+
+	err = respondWithJSON(w, http.StatusOK, res)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error sending response", "error", err)
+	}
+}
+
+func handleGetMe(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	tokenID, ok := userIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, r, errUnauthorized())
+		return
+	}
+
+	user, err := cfg.db.GetUserWithId(ctx, tokenID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, errNotFound("User not found"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("getting user %s: %w", tokenID, err)))
+		return
+	}
+
+	chirpCount, err := cfg.db.CountUserChirps(ctx, user.ID)
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("counting chirps of user %s: %w", user.ID, err)))
+		return
+	}
+
+	err = respondWithJSON(w, http.StatusOK, ownProfileFromDB(user, chirpCount))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error sending response", "error", err)
+	}
+}
+
+func handleGetUser(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, errInvalidParameter("userID", "Invalid user ID"))
+		return
+	}
+
+	user, err := cfg.db.GetUserWithId(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, errNotFound("User not found"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("getting user %s: %w", userID, err)))
+		return
+	}
+
+	chirpCount, err := cfg.db.CountUserChirps(ctx, user.ID)
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("counting chirps of user %s: %w", user.ID, err)))
+		return
+	}
+
+	err = respondWithJSON(w, http.StatusOK, profileFromDB(user, chirpCount))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error sending response", "error", err)
+	}
+}
+
+func handleUpdateProfile(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	tokenID, ok := userIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, r, errUnauthorized())
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := profileUpdate{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, errInvalidJSON())
+		return
+	}
+
+	user, err := cfg.db.GetUserWithId(ctx, tokenID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, errNotFound("User not found"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("getting user %s: %w", tokenID, err)))
+		return
+	}
+
+	update, err := params.apply(user)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	user, err = cfg.db.UpdateUserProfile(ctx, update)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, errNotFound("User not found"))
+		return
+	}
+	if isUniqueViolation(err, "users_handle_lower_idx") {
+		respondWithError(w, r, newAPIError(http.StatusConflict, CODE_ALREADY_EXISTS, "Handle is already taken"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("updating profile of user %s: %w", tokenID, err)))
+		return
+	}
+
+	chirpCount, err := cfg.db.CountUserChirps(ctx, user.ID)
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("counting chirps of user %s: %w", user.ID, err)))
+		return
+	}
+
+	err = respondWithJSON(w, http.StatusOK, ownProfileFromDB(user, chirpCount))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error sending response", "error", err)
+	}
+}
+
+func handleGetMentions(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	type Response struct {
+		Chirps     []Chirp `json:"chirps"`
+		NextCursor string  `json:"next_cursor,omitempty"`
+	}
+
+	tokenID, ok := userIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, r, errUnauthorized())
+		return
+	}
+
+	query := r.URL.Query()
+
+	page, err := parsePageRequest(query)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	// Newest first, with one extra row to know whether there is a next page.
+	chirps, err := cfg.db.ListChirpsMentioningUser(ctx, database.ListChirpsMentioningUserParams{
+		UserID:          tokenID,
+		CursorCreatedAt: page.CursorCreatedAt,
+		CursorID:        page.CursorID,
+		PageLimit:       page.fetchLimit(),
+	})
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("listing mentions of user %s: %w", tokenID, err)))
+		return
+	}
+
+	res := Response{
+		Chirps: []Chirp{},
+	}
+
+	chirps, res.NextCursor = paginate(chirps, page, chirpPosition)
+	for _, chirp := range chirps {
+		res.Chirps = append(res.Chirps, chirpFromDB(chirp))
+	}
+
+	err = markLiked(ctx, cfg.db, tokenID, res.Chirps)
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("checking likes: %w", err)))
+		return
+	}
+
+	err = respondWithJSON(w, http.StatusOK, res)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error sending response", "error", err)
+	}
+}
+
+func handleFollowUser(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	tokenID, ok := userIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, r, errUnauthorized())
+		return
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, errInvalidParameter("userID", "Invalid user ID"))
+		return
+	}
+
+	if userID == tokenID {
+		respondWithError(w, r, errInvalidParameter("userID", "Users can't follow themselves"))
+		return
+	}
+
+	_, err = cfg.db.GetUserWithId(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, errNotFound("User not found"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("getting user %s: %w", userID, err)))
+		return
+	}
+
+	// Following someone twice is not an error.
+	err = cfg.db.CreateFollow(ctx, database.CreateFollowParams{
+		FollowerID: tokenID,
+		FolloweeID: userID,
+	})
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("following user %s: %w", userID, err)))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleUnfollowUser(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	tokenID, ok := userIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, r, errUnauthorized())
+		return
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, errInvalidParameter("userID", "Invalid user ID"))
+		return
+	}
+
+	err = cfg.db.DeleteFollow(ctx, database.DeleteFollowParams{
+		FollowerID: tokenID,
+		FolloweeID: userID,
+	})
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("unfollowing user %s: %w", userID, err)))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleGetFollowers(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	handleFollowList(w, r, cfg, "followers", func(ctx context.Context, userID uuid.UUID, page pageRequest) ([]database.ListFollowersRow, error) {
+		return cfg.db.ListFollowers(ctx, database.ListFollowersParams{
+			UserID:          userID,
+			CursorCreatedAt: page.CursorCreatedAt,
+			CursorID:        page.CursorID,
+			PageLimit:       page.fetchLimit(),
+		})
+	})
+}
+
+func handleGetFollowing(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	handleFollowList(w, r, cfg, "following", func(ctx context.Context, userID uuid.UUID, page pageRequest) ([]database.ListFollowingRow, error) {
+		return cfg.db.ListFollowing(ctx, database.ListFollowingParams{
+			UserID:          userID,
+			CursorCreatedAt: page.CursorCreatedAt,
+			CursorID:        page.CursorID,
+			PageLimit:       page.fetchLimit(),
+		})
+	})
+}
+
+// handleFollowList serves a page of the users that list returns for the user
+// in the path, most recent follow first.
+func handleFollowList[Row followRow](w http.ResponseWriter, r *http.Request, cfg *apiConfig, name string, list func(context.Context, uuid.UUID, pageRequest) ([]Row, error)) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	type Response struct {
+		Users      []FollowUser `json:"users"`
+		NextCursor string       `json:"next_cursor,omitempty"`
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, errInvalidParameter("userID", "Invalid user ID"))
+		return
+	}
+
+	query := r.URL.Query()
+
+	page, err := parsePageRequest(query)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	_, err = cfg.db.GetUserWithId(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, errNotFound("User not found"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("getting user %s: %w", userID, err)))
+		return
+	}
+
+	rows, err := list(ctx, userID, page)
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("listing %s of user %s: %w", name, userID, err)))
+		return
+	}
+
+	users := make([]FollowUser, 0, len(rows))
+	for _, row := range rows {
+		users = append(users, followUserFromDB(row))
+	}
+
+	res := Response{}
+	res.Users, res.NextCursor = paginate(users, page, func(user FollowUser) pageCursor {
+		return pageCursor{CreatedAt: user.FollowedAt, ID: user.Id}
+	})
+
+	err = respondWithJSON(w, http.StatusOK, res)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error sending response", "error", err)
+	}
+}
+
+func handleGetTimeline(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	type Response struct {
+		Chirps     []Chirp `json:"chirps"`
+		NextCursor string  `json:"next_cursor,omitempty"`
+	}
+
+	tokenID, ok := userIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, r, errUnauthorized())
+		return
+	}
+
+	query := r.URL.Query()
+
+	page, err := parsePageRequest(query)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	// The query takes at most a page from each followed user through
+	// chirps_user_id_created_at_id_idx before merging, so the cost grows
+	// with the page size and the number of follows rather than with the
+	// number of chirps they have written.
+	chirps, err := cfg.db.ListTimeline(ctx, database.ListTimelineParams{
+		CursorCreatedAt: page.CursorCreatedAt,
+		CursorID:        page.CursorID,
+		PageLimit:       page.fetchLimit(),
+		UserID:          tokenID,
+	})
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("listing timeline of user %s: %w", tokenID, err)))
+		return
+	}
+
+	res := Response{
+		Chirps: []Chirp{},
+	}
+
+	chirps, res.NextCursor = paginate(chirps, page, chirpPosition)
+	for _, chirp := range chirps {
+		res.Chirps = append(res.Chirps, chirpFromDB(chirp))
+	}
+
+	err = markLiked(ctx, cfg.db, tokenID, res.Chirps)
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("checking likes: %w", err)))
+		return
+	}
+
+	err = respondWithJSON(w, http.StatusOK, res)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error sending response", "error", err)
+	}
+}
+
+func handleDeleteUser(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	type Parameters struct {
+		Password string `json:"password"`
+	}
+
+	tokenID, ok := userIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, r, errUnauthorized())
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := Parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, errInvalidJSON())
+		return
+	}
+
+	user, err := cfg.db.GetUserWithId(ctx, tokenID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, errNotFound("User not found"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("getting user %s: %w", tokenID, err)))
+		return
+	}
+
+	// A stolen access token alone is not enough to delete the account.
+	err = auth.CheckPasswordHash(params.Password, user.HashedPassowrd)
+	if err != nil {
+		respondWithError(w, r, newAPIError(http.StatusUnauthorized, CODE_INVALID_CREDENTIALS, "Incorrect password"))
+		return
+	}
+
+	err = cfg.deleteUser(ctx, user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, errNotFound("User not found"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("deleting user %s: %w", user.ID, err)))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleExportUser(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	type ExportedChirp struct {
+		Chirp
+		Revisions []ChirpRevision `json:"revisions"`
+	}
+
+	type Response struct {
+		ExportedAt time.Time       `json:"exported_at"`
+		User       OwnProfile      `json:"user"`
+		Chirps     []ExportedChirp `json:"chirps"`
+	}
+
+	tokenID, ok := userIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, r, errUnauthorized())
+		return
+	}
+
+	// One snapshot, so a chirp edited halfway through the export can't show
+	// up with revisions from after the body that was read.
+	tx, err := cfg.dbConn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("starting transaction: %w", err)))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
+
+	user, err := qtx.GetUserWithId(ctx, tokenID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, errNotFound("User not found"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("getting user %s: %w", tokenID, err)))
+		return
+	}
+
+	chirps, err := qtx.ListUserChirps(ctx, user.ID)
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("listing chirps of user %s: %w", user.ID, err)))
+		return
+	}
+
+	revisions, err := qtx.ListUserChirpRevisions(ctx, user.ID)
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("listing revisions of user %s: %w", user.ID, err)))
+		return
+	}
+
+	byChirp := map[uuid.UUID][]ChirpRevision{}
+	for _, revision := range revisions {
+		byChirp[revision.ChirpID] = append(byChirp[revision.ChirpID], ChirpRevision{
+			CreatedAt: revision.CreatedAt,
+			Body:      revision.Body,
+			Id:        revision.ID,
+		})
+	}
+
+	res := Response{
+		ExportedAt: time.Now().UTC(),
+		User:       ownProfileFromDB(user, int64(len(chirps))),
+		Chirps:     []ExportedChirp{},
+	}
+	exported := []Chirp{}
+	for _, chirp := range chirps {
+		exported = append(exported, chirpFromDB(chirp))
+	}
+	err = markLiked(ctx, qtx, user.ID, exported)
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("checking likes of user %s: %w", user.ID, err)))
+		return
+	}
+
+	for _, chirp := range exported {
+		chirpRevisions := byChirp[chirp.Id]
+		if chirpRevisions == nil {
+			chirpRevisions = []ChirpRevision{}
+		}
+		res.Chirps = append(res.Chirps, ExportedChirp{Chirp: chirp, Revisions: chirpRevisions})
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="chirpy-export-%s.json"`, user.ID))
+	err = respondWithJSON(w, http.StatusOK, res)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error sending response", "error", err)
+	}
+}
+
+func handlePolkaWebhook(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	type Parameters struct {
+		Event string `json:"event"`
+		Data  struct {
+			UserID uuid.UUID `json:"user_id"`
+		} `json:"data"`
+	}
+
+	apiKey, err := auth.GetAPIKey(r.Header.Clone())
+	if err != nil || cfg.polkaKey == "" || subtle.ConstantTimeCompare([]byte(apiKey), []byte(cfg.polkaKey)) != 1 {
+		respondWithError(w, r, newAPIError(http.StatusUnauthorized, CODE_INVALID_API_KEY, "Invalid API key"))
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := Parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, errInvalidJSON())
+		return
+	}
+
+	if params.Event != "user.upgraded" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// Upgrading is a plain SET, so replayed events leave the user unchanged.
+	_, err = cfg.db.UpgradeUserToChirpyRed(ctx, params.Data.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, errNotFound("User not found"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("upgrading user %s: %w", params.Data.UserID, err)))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleRevoke(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	token, err := auth.GetBearerToken(r.Header.Clone())
+	if err != nil {
+		respondWithError(w, r, newAPIError(http.StatusBadRequest, CODE_INVALID_TOKEN, "Missing or malformed Authorization header"))
+		return
+	}
+
+	tokenDB, err := cfg.db.GetRefreshToken(ctx, token)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, newAPIError(http.StatusUnauthorized, CODE_INVALID_TOKEN, "Invalid refresh token"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("getting refresh token: %w", err)))
+		return
+	}
+
+	err = cfg.db.RevokeRefreshToken(ctx, tokenDB.Token)
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("revoking refresh token: %w", err)))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleRefresh(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	token, err := auth.GetBearerToken(r.Header.Clone())
+	if err != nil {
+		respondWithError(w, r, newAPIError(http.StatusBadRequest, CODE_INVALID_TOKEN, "Missing or malformed Authorization header"))
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("starting transaction: %w", err)))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
+
+	tokenDB, err := qtx.GetRefreshTokenForUpdate(ctx, token)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, newAPIError(http.StatusUnauthorized, CODE_INVALID_TOKEN, "Invalid refresh token"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("getting refresh token: %w", err)))
+		return
+	}
+
+	// A revoked token coming back means it was copied, so everything issued
+	// from the same login is no longer trusted.
+	if tokenDB.RevokedAt.Valid {
+		err = qtx.RevokeRefreshTokenFamily(ctx, tokenDB.FamilyID)
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error revoking refresh token family", "family_id", tokenDB.FamilyID, "error", err)
+		}
+		slog.WarnContext(r.Context(), "Refresh token reuse detected, revoked family", "user_id", tokenDB.UserID, "family_id", tokenDB.FamilyID)
+		respondWithError(w, r, newAPIError(http.StatusUnauthorized, CODE_INVALID_TOKEN, "Refresh token has been revoked"))
+		return
+	}
+
+	if time.Now().UTC().After(tokenDB.ExpiresAt) {
+		respondWithError(w, r, newAPIError(http.StatusUnauthorized, CODE_INVALID_TOKEN, "Refresh token has expired"))
+		return
+	}
+
+	newRefreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, r, errInternal(fmt.Errorf("making refresh token: %w", err)))
+		return
+	}
+
+	_, err = qtx.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		Token:     newRefreshToken,
+		UserID:    tokenDB.UserID,
+		ExpiresAt: time.Now().UTC().Add(cfg.refreshTokenTTL),
+		FamilyID:  tokenDB.FamilyID,
+	})
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("creating refresh token: %w", err)))
+		return
+	}
+
+	err = qtx.RotateRefreshToken(ctx, database.RotateRefreshTokenParams{
+		Token:      tokenDB.Token,
+		ReplacedBy: sql.NullString{String: newRefreshToken, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("rotating refresh token: %w", err)))
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("commiting refresh token rotation: %w", err)))
+		return
+	}
+
+	newToken, err := cfg.makeAccessToken(tokenDB.UserID)
+	if err != nil {
+		respondWithError(w, r, errInternal(fmt.Errorf("making access token: %w", err)))
+		return
+	}
+
+	type Resposne struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	res := Resposne{
+		Token:        newToken,
+		RefreshToken: newRefreshToken,
+	}
+
+	err = respondWithJSON(w, http.StatusOK, res)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error sending response", "error", err)
+	}
+}
+
+var adminMetricsTemplate = template.Must(template.New("metrics").Parse(`
+    <html>
+      <body>
+        <h1>Welcome, Chirpy Admin</h1>
+        <p>Chirpy has been visited {{.Hits}} times!</p>
+        <table>
+          <tr><th>Metric</th><th>Labels</th><th>Value</th></tr>
+          {{- range .Rows}}
+          <tr><td>{{.Name}}</td><td>{{.Labels}}</td><td>{{.Value}}</td></tr>
+          {{- end}}
+        </table>
+      </body>
+    </html>
+    `))
+
+// handleMetrics renders the chirpy_ series served on /metrics as a page for
+// humans. Histograms are shown as their count and mean.
+func handleMetrics(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	type Row struct {
+		Name   string
+		Labels string
+		Value  string
+	}
+
+	families, err := cfg.metrics.registry.Gather()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error gathering metrics", "error", err)
+	}
+
+	var rows []Row
+	for _, family := range families {
+		if !strings.HasPrefix(family.GetName(), "chirpy_") {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			var labels []string
+			for _, label := range metric.GetLabel() {
+				labels = append(labels, label.GetName()+"="+label.GetValue())
+			}
+
+			value := ""
+			switch {
+			case metric.Counter != nil:
+				value = strconv.FormatFloat(metric.GetCounter().GetValue(), 'f', -1, 64)
+			case metric.Gauge != nil:
+				value = strconv.FormatFloat(metric.GetGauge().GetValue(), 'f', -1, 64)
+			case metric.Histogram != nil:
+				h := metric.GetHistogram()
+				mean := 0.0
+				if h.GetSampleCount() > 0 {
+					mean = h.GetSampleSum() / float64(h.GetSampleCount())
+				}
+				value = fmt.Sprintf("count %d, mean %.4fs", h.GetSampleCount(), mean)
+			}
+
+			rows = append(rows, Row{
+				Name:   family.GetName(),
+				Labels: strings.Join(labels, ", "),
+				Value:  value,
+			})
+		}
+	}
+
+	w.Header().Add("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	err = adminMetricsTemplate.Execute(w, struct {
+		Hits int64
+		Rows []Row
+	}{
+		Hits: cfg.fileserverHits.Load(),
+		Rows: rows,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error rendering metrics", "error", err)
+	}
+}
+
+func handleJWKS(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	err := respondWithJSON(w, http.StatusOK, auth.JWKS(cfg.keys))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error sending response", "error", err)
+	}
+}
+
+func handleHealthz(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
+	if cfg.draining.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("Draining"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+func handleReset(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	if cfg.platform != "dev" {
+		respondWithError(w, r, newAPIError(http.StatusForbidden, CODE_FORBIDDEN, "Reset is only allowed on the dev platform"))
+		return
+	}
+	cfg.fileserverHits.Store(0)
+	err := cfg.db.ResetUsers(ctx)
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("reseting users: %w", err)))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func handleListBannedWords(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	words, err := cfg.db.ListBannedWords(ctx)
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("listing banned words: %w", err)))
+		return
+	}
+
+	res := []BannedWord{}
+	for _, word := range words {
+		res = append(res, BannedWord{
+			CreatedAt: word.CreatedAt,
+			Word:      word.Word,
+			Id:        word.ID,
+		})
+	}
+
+	err = respondWithJSON(w, http.StatusOK, res)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error sending response", "error", err)
+	}
+}
+
+func handleCreateBannedWord(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	type Parameters struct {
+		Word string `json:"word"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := Parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, errInvalidJSON())
+		return
+	}
+
+	// The filter matches single words, so anything with spaces in it could
+	// never match.
+	word := strings.TrimSpace(params.Word)
+	if word == "" || strings.ContainsFunc(word, unicode.IsSpace) {
+		respondWithError(w, r, errInvalidParameter("word", "Expected a single word"))
+		return
+	}
+
+	banned, err := cfg.db.CreateBannedWord(ctx, word)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, newAPIError(http.StatusConflict, CODE_ALREADY_EXISTS, "Word is already banned"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("creating banned word: %w", err)))
+		return
+	}
+
+	cfg.reloadBannedWords(ctx)
+
+	err = respondWithJSON(w, http.StatusCreated, BannedWord{
+		CreatedAt: banned.CreatedAt,
+		Word:      banned.Word,
+		Id:        banned.ID,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error sending response", "error", err)
+	}
+}
+
+func handleDeleteBannedWord(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	deleted, err := cfg.db.DeleteBannedWord(ctx, r.PathValue("word"))
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("deleting banned word: %w", err)))
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, r, errNotFound("Word is not banned"))
+		return
+	}
+
+	cfg.reloadBannedWords(ctx)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleAdminDeleteUser(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, errInvalidParameter("userID", "Invalid user ID"))
+		return
+	}
+
+	_, err = cfg.softDeleteUser(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, errNotFound("User not found"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("deleting user %s: %w", userID, err)))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleAdminRestoreUser(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	type Response struct {
+		CreatedAt   time.Time `json:"created_at"`
+		UpdatedAt   time.Time `json:"updated_at"`
+		Email       string    `json:"email"`
+		Id          uuid.UUID `json:"id"`
+		IsChirpyRed bool      `json:"is_chirpy_red"`
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, errInvalidParameter("userID", "Invalid user ID"))
+		return
+	}
+
+	user, err := cfg.restoreUser(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, errNotFound("No deleted user with this ID within the retention window"))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("restoring user %s: %w", userID, err)))
+		return
+	}
+
+	err = respondWithJSON(w, http.StatusOK, Response{
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		Id:          user.ID,
+		IsChirpyRed: user.IsChirpyRed,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error sending response", "error", err)
+	}
 }
 
-func handlePutUsers(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
-	type Parameters struct {
-		Password string `json:"password"`
-		Email    string `json:"email"`
-	}
+func handleAdminDeleteChirp(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
 
-	tokenHeaderValue, err := auth.GetBearerToken(r.Header.Clone())
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, err.Error())
+		respondWithError(w, r, errInvalidParameter("chirpID", "Invalid chirp ID"))
 		return
 	}
 
-	tokenId, err := auth.ValidateJWT(tokenHeaderValue, cfg.secret)
+	deleted, err := cfg.db.SoftDeleteChirp(ctx, chirpID)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, err.Error())
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("deleting chirp %s: %w", chirpID, err)))
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, r, errNotFound("Chirp not found"))
 		return
 	}
 
-	decoder := json.NewDecoder(r.Body)
-	params := Parameters{}
-	err = decoder.Decode(&params)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleAdminRestoreChirp(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, errInvalidParameter("chirpID", "Invalid chirp ID"))
 		return
 	}
 
-	newHashedPasswd, err := auth.HashedPassword(params.Password)
+	// Chirps of a deleted user come back with the user, not on their own.
+	chirp, err := cfg.db.RestoreChirp(ctx, database.RestoreChirpParams{
+		ID:               chirpID,
+		RetentionSeconds: cfg.deletedRetention.Seconds(),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, errNotFound("No deleted chirp with this ID within the retention window"))
+		return
+	}
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("restoring chirp %s: %w", chirpID, err)))
+		return
 	}
 
-	user, err := cfg.db.UpdateUserEmailAndPassword(context.Background(), database.UpdateUserEmailAndPasswordParams{
-		Email:          params.Email,
-		HashedPassowrd: newHashedPasswd,
-		ID:             tokenId,
-	})
+	err = respondWithJSON(w, http.StatusOK, chirpFromDB(chirp))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error sending response", "error", err)
+	}
+}
 
+func handleValidateChirp(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
 	type Response struct {
-		Email string `json:"email"`
+		CleanedBody string `json:"cleaned_body"`
+		Valid       bool   `json:"valid"`
+		Flagged     bool   `json:"flagged,omitempty"`
+	}
+
+	type Parameters struct {
+		Body string `json:"body"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := Parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, errInvalidJSON())
+		return
+	}
+
+	chirp, err := cfg.validateChirp(params.Body)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
 	}
 
 	res := Response{
-		Email: user.Email,
+		Valid:       true,
+		CleanedBody: chirp.Body,
+		Flagged:     chirp.Flagged,
 	}
 
 	err = respondWithJSON(w, http.StatusOK, res)
 	if err != nil {
-		log.Printf("Error sending response: %s", err.Error())
+		slog.ErrorContext(r.Context(), "Error sending response", "error", err)
 	}
 }
 
-func handleRevoke(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
-	token, err := auth.GetBearerToken(r.Header.Clone())
+func handleGetChirp(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	chirp_id, err := uuid.Parse(r.PathValue("chirpID"))
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, errInvalidParameter("chirpID", "Invalid chirp ID"))
+		return
+	}
+	chirp, err := cfg.db.GetChirpWithId(ctx, chirp_id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, errNotFound("Chirp not found"))
 		return
 	}
-
-	tokenDB, err := cfg.db.GetRefreshToken(context.Background(), token)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, err.Error())
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("getting chirp %s: %w", chirp_id, err)))
 		return
 	}
 
-	err = cfg.db.RevokeRefreshToken(context.Background(), tokenDB.Token)
+	viewerID, _ := userIDFromContext(r.Context())
+	res := []Chirp{chirpFromDB(chirp)}
+	err = markLiked(ctx, cfg.db, viewerID, res)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("checking likes: %w", err)))
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	err = respondWithJSON(w, http.StatusOK, res[0])
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error sending resposne", "error", err)
+		return
+	}
 }
 
-func handleRefresh(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
-	token, err := auth.GetBearerToken(r.Header.Clone())
-	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+// handleUpdateChirp replaces the body of a chirp and keeps the old body as a
+// revision. The chirp row is locked so concurrent edits can't lose one.
+func handleUpdateChirp(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	type Parameters struct {
+		Body string `json:"body"`
+	}
+
+	tokenID, ok := userIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, r, errUnauthorized())
 		return
 	}
 
-	tokenDB, err := cfg.db.GetRefreshToken(context.Background(), token)
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, err.Error())
+		respondWithError(w, r, errInvalidParameter("chirpID", "Invalid chirp ID"))
 		return
 	}
 
-	if time.Now().UTC().After(tokenDB.ExpiresAt) || tokenDB.RevokedAt.Valid {
-		respondWithError(w, http.StatusUnauthorized, "token expired")
+	decoder := json.NewDecoder(r.Body)
+	params := Parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, errInvalidJSON())
 		return
 	}
 
-	newToken, err := auth.MakeJWT(tokenDB.UserID, cfg.secret, time.Hour)
+	validated, err := cfg.validateChirp(params.Body)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithError(w, r, err)
 		return
 	}
 
-	type Resposne struct {
-		Token string `json:"token"`
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("starting transaction: %w", err)))
+		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
 
-	res := Resposne{
-		Token: newToken,
+	chirp, err := qtx.GetChirpForUpdate(ctx, chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, errNotFound("Chirp not found"))
+		return
 	}
-
-	err = respondWithJSON(w, http.StatusOK, res)
 	if err != nil {
-		log.Printf("Error sending response: %s", err.Error())
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("getting chirp %s: %w", chirpID, err)))
+		return
 	}
-}
 
-func handleMetrics(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
-	w.Header().Add("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
+	if chirp.UserID != tokenID {
+		respondWithError(w, r, newAPIError(http.StatusForbidden, CODE_FORBIDDEN, "Not the author of this chirp"))
+		return
+	}
 
-	html := `
-    <html>
-      <body>
-        <h1>Welcome, Chirpy Admin</h1>
-        <p>Chirpy has been visited %d times!</p>
-      </body>
-    </html>
-    `
+	// Saving the same body again is not an edit.
+	if validated.Body != chirp.Body {
+		err = qtx.CreateChirpRevision(ctx, database.CreateChirpRevisionParams{
+			ChirpID: chirp.ID,
+			Body:    chirp.Body,
+		})
+		if err != nil {
+			respondWithError(w, r, errDatabase(ctx, fmt.Errorf("saving revision of chirp %s: %w", chirp.ID, err)))
+			return
+		}
 
-	fmt.Fprintf(w, html, cfg.fileserverHits.Load())
-}
+		chirp, err = qtx.UpdateChirpBody(ctx, database.UpdateChirpBodyParams{
+			ID:      chirp.ID,
+			Body:    validated.Body,
+			Flagged: validated.Flagged,
+		})
+		if err != nil {
+			respondWithError(w, r, errDatabase(ctx, fmt.Errorf("updating chirp %s: %w", chirpID, err)))
+			return
+		}
 
-func handleHealthz(w http.ResponseWriter, r *http.Request) {
-	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
-}
+		err = qtx.DeleteChirpMentions(ctx, chirp.ID)
+		if err != nil {
+			respondWithError(w, r, errDatabase(ctx, fmt.Errorf("clearing mentions of chirp %s: %w", chirp.ID, err)))
+			return
+		}
 
-func handleReset(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
-	if cfg.platform != "dev" {
-		w.WriteHeader(http.StatusForbidden)
-		return
+		err = saveMentions(ctx, qtx, chirp)
+		if err != nil {
+			respondWithError(w, r, errDatabase(ctx, fmt.Errorf("saving mentions of chirp %s: %w", chirp.ID, err)))
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			respondWithError(w, r, errDatabase(ctx, fmt.Errorf("commiting edit of chirp %s: %w", chirpID, err)))
+			return
+		}
+
+		if chirp.Flagged {
+			slog.WarnContext(r.Context(), "Chirp flagged for review", "chirp_id", chirp.ID, "words", validated.Words)
+		}
 	}
-	cfg.fileserverHits.Store(0)
-	err := cfg.db.ResetUsers(context.Background())
+
+	res := []Chirp{chirpFromDB(chirp)}
+	err = markLiked(ctx, cfg.db, tokenID, res)
 	if err != nil {
-		log.Printf("Error reseting user database: %s", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("checking likes: %w", err)))
 		return
 	}
-	w.WriteHeader(http.StatusOK)
+
+	err = respondWithJSON(w, http.StatusOK, res[0])
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error sending response", "error", err)
+	}
 }
 
-func handleValidateChirp(w http.ResponseWriter, r *http.Request) {
+func handleGetChirpRevisions(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
 	type Response struct {
-		Error       string `json:"error,omitempty"`
-		CleanedBody string `json:"cleaned_body,omitempty"`
-		Valid       bool   `json:"valid,omitempty"`
+		Revisions []ChirpRevision `json:"revisions"`
 	}
 
-	type Parameters struct {
-		Body string `json:"body"`
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, r, errInvalidParameter("chirpID", "Invalid chirp ID"))
+		return
 	}
 
-	decoder := json.NewDecoder(r.Body)
-	params := Parameters{}
-	err := decoder.Decode(&params)
+	_, err = cfg.db.GetChirpWithId(ctx, chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, errNotFound("Chirp not found"))
+		return
+	}
 	if err != nil {
-		log.Printf("Error decoding parameters: %s", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("getting chirp %s: %w", chirpID, err)))
 		return
 	}
 
-	code := http.StatusOK
+	revisions, err := cfg.db.ListChirpRevisions(ctx, chirpID)
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("listing revisions of chirp %s: %w", chirpID, err)))
+		return
+	}
 
 	res := Response{
-		Valid:       true,
-		CleanedBody: validateProfaneLogic(params.Body, PROFANE),
+		Revisions: []ChirpRevision{},
+	}
+	for _, revision := range revisions {
+		res.Revisions = append(res.Revisions, ChirpRevision{
+			CreatedAt: revision.CreatedAt,
+			Body:      revision.Body,
+			Id:        revision.ID,
+		})
 	}
 
-	if len(params.Body) > 140 {
-		res = Response{
-			Error: "Chirp is too long",
-		}
+	err = respondWithJSON(w, http.StatusOK, res)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error sending response", "error", err)
+	}
+}
 
-		code = http.StatusBadRequest
+func handleLikeChirp(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	setLike(w, r, cfg, true)
+}
+
+func handleUnlikeChirp(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	setLike(w, r, cfg, false)
+}
+
+// setLike serves both like endpoints and responds with the chirp as the
+// caller now sees it.
+func setLike(w http.ResponseWriter, r *http.Request, cfg *apiConfig, liked bool) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	tokenID, ok := userIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, r, errUnauthorized())
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, r, errInvalidParameter("chirpID", "Invalid chirp ID"))
+		return
 	}
 
-	dat, err := json.Marshal(res)
+	chirp, err := cfg.setChirpLike(ctx, chirpID, tokenID, liked)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, errNotFound("Chirp not found"))
+		return
+	}
 	if err != nil {
-		log.Printf("Error marshalling JSON: %s", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("setting like on chirp %s: %w", chirpID, err)))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	w.Write(dat)
+	res := chirpFromDB(chirp)
+	res.LikedByMe = liked
+
+	err = respondWithJSON(w, http.StatusOK, res)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error sending response", "error", err)
+	}
 }
 
-func handleGetChirp(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
-	chirp_id, err := uuid.Parse(r.PathValue("chirpID"))
+func handleDeleteChirp(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	tokenID, ok := userIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, r, errUnauthorized())
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
 	if err != nil {
-		log.Printf("Error parsing uuid: %s", err)
-		err = respondWithError(w, http.StatusBadRequest, "Invalid id")
-		if err != nil {
-			log.Printf("Error sending response: %s", err)
-			return
-		}
+		respondWithError(w, r, errInvalidParameter("chirpID", "Invalid chirp ID"))
+		return
+	}
+
+	chirp, err := cfg.db.GetChirpWithId(ctx, chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, errNotFound("Chirp not found"))
 		return
 	}
-	chirp, err := cfg.db.GetChirpWithId(context.Background(), chirp_id)
 	if err != nil {
-		log.Printf("Error geting chirp with id: %s, %s", chirp_id, err)
-		err = respondWithError(w, http.StatusNotFound, "Chrip not found")
-		if err != nil {
-			log.Printf("Error sending error response: %s", err)
-			return
-		}
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("getting chirp %s: %w", chirpID, err)))
 		return
 	}
 
-	res_chirp := Chirp{
-		Id:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
+	if chirp.UserID != tokenID {
+		respondWithError(w, r, newAPIError(http.StatusForbidden, CODE_FORBIDDEN, "Not the author of this chirp"))
+		return
 	}
 
-	err = respondWithJSON(w, http.StatusOK, res_chirp)
+	_, err = cfg.db.SoftDeleteChirp(ctx, chirp.ID)
 	if err != nil {
-		log.Printf("Error sending resposne: %s", err)
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("deleting chirp %s: %w", chirp.ID, err)))
 		return
 	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func handleLogin(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
 	type Parameters struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
@@ -275,40 +1554,54 @@ func handleLogin(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
 	params := Parameters{}
 	err := decoder.Decode(&params)
 	if err != nil {
-		log.Printf("Error decoding JSON: %s", err)
-		respondWithError(w, http.StatusInternalServerError, "Error decoding json")
+		respondWithError(w, r, errInvalidJSON())
 		return
 	}
 
-	user, err := cfg.db.GetUserWithEmail(context.Background(), params.Email)
+	// Unknown emails and wrong passwords get the same response so the
+	// endpoint can't be used to find out who has an account.
+	invalidCredentials := newAPIError(http.StatusUnauthorized, CODE_INVALID_CREDENTIALS, "Incorrect email or password")
+
+	user, err := cfg.db.GetUserWithEmail(ctx, params.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		cfg.metrics.failedLogins.Inc()
+		respondWithError(w, r, invalidCredentials)
+		return
+	}
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, err.Error())
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("getting user with email: %w", err)))
 		return
 	}
 
 	err = auth.CheckPasswordHash(params.Password, user.HashedPassowrd)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Wrong password")
+		cfg.metrics.failedLogins.Inc()
+		respondWithError(w, r, invalidCredentials)
 		return
 	}
 
-	token, err := auth.MakeJWT(user.ID, cfg.secret, time.Duration(time.Hour))
+	token, err := cfg.makeAccessToken(user.ID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithError(w, r, errInternal(fmt.Errorf("making access token: %w", err)))
 		return
 	}
 
 	refreshToken, err := auth.MakeRefreshToken()
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithError(w, r, errInternal(fmt.Errorf("making refresh token: %w", err)))
 		return
 	}
 
-	_, err = cfg.db.CreateRefreshToken(context.Background(), database.CreateRefreshTokenParams{
+	_, err = cfg.db.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
 		Token:     refreshToken,
 		UserID:    user.ID,
-		ExpiresAt: time.Now().UTC().Add(time.Hour * 1440),
+		ExpiresAt: time.Now().UTC().Add(cfg.refreshTokenTTL),
+		FamilyID:  uuid.New(),
 	})
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("creating refresh token: %w", err)))
+		return
+	}
 
 	res := User{
 		CreatedAt:    user.CreatedAt,
@@ -317,50 +1610,97 @@ func handleLogin(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
 		Email:        user.Email,
 		Token:        token,
 		RefreshToken: refreshToken,
+		IsChirpyRed:  user.IsChirpyRed,
 	}
 
+	cfg.metrics.logins.Inc()
 	respondWithJSON(w, http.StatusOK, res)
 }
 
 func handleGetAllChirps(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
-	chirps, err := cfg.db.GetAllChirps(context.Background())
-	if err != nil {
-		log.Printf("Error retriving chirps: %s", err)
-		err = respondWithError(w, http.StatusInternalServerError, "retriving chirps")
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	type Response struct {
+		Chirps     []Chirp `json:"chirps"`
+		NextCursor string  `json:"next_cursor,omitempty"`
+	}
+
+	query := r.URL.Query()
+
+	sortOrder := query.Get("sort")
+	if sortOrder == "" {
+		sortOrder = "asc"
+	}
+	if sortOrder != "asc" && sortOrder != "desc" {
+		respondWithError(w, r, errInvalidParameter("sort", "Invalid sort, expected asc or desc"))
+		return
+	}
+
+	authorID := uuid.NullUUID{}
+	if s := query.Get("author_id"); s != "" {
+		id, err := uuid.Parse(s)
 		if err != nil {
-			log.Printf("Error sending error response: %s", err)
+			respondWithError(w, r, errInvalidParameter("author_id", "Invalid author_id"))
 			return
 		}
+		authorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	page, err := parsePageRequest(query)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	// Fetch one extra row to know whether there is a next page.
+	var chirps []database.Chirp
+	if sortOrder == "desc" {
+		chirps, err = cfg.db.ListChirpsDesc(ctx, database.ListChirpsDescParams{
+			UserID:          authorID,
+			CursorCreatedAt: page.CursorCreatedAt,
+			CursorID:        page.CursorID,
+			PageLimit:       page.fetchLimit(),
+		})
+	} else {
+		chirps, err = cfg.db.ListChirpsAsc(ctx, database.ListChirpsAscParams{
+			UserID:          authorID,
+			CursorCreatedAt: page.CursorCreatedAt,
+			CursorID:        page.CursorID,
+			PageLimit:       page.fetchLimit(),
+		})
+	}
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("listing chirps: %w", err)))
 		return
 	}
 
-	var chirpsRes []Chirp
+	res := Response{
+		Chirps: []Chirp{},
+	}
 
+	chirps, res.NextCursor = paginate(chirps, page, chirpPosition)
 	for _, chirp := range chirps {
-		chirpsRes = append(chirpsRes, Chirp{
-			Id:        chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body:      chirp.Body,
-			UserID:    chirp.UserID,
-		})
+		res.Chirps = append(res.Chirps, chirpFromDB(chirp))
+	}
+
+	viewerID, _ := userIDFromContext(r.Context())
+	err = markLiked(ctx, cfg.db, viewerID, res.Chirps)
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("checking likes: %w", err)))
+		return
 	}
 
-	err = respondWithJSON(w, http.StatusOK, chirpsRes)
+	err = respondWithJSON(w, http.StatusOK, res)
 	if err != nil {
-		log.Printf("Error sending resposne: %s", err)
+		slog.ErrorContext(r.Context(), "Error sending resposne", "error", err)
 		return
 	}
 }
 
 func handleCreateChirp(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
-	type Response struct {
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-		Body      string    `json:"body"`
-		UserID    uuid.UUID `json:"user_id"`
-		Id        uuid.UUID `json:"id"`
-	}
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
 
 	type Parameters struct {
 		Body string `json:"body"`
@@ -370,59 +1710,77 @@ func handleCreateChirp(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
 	params := Parameters{}
 	err := decoder.Decode(&params)
 	if err != nil {
-		log.Printf("Error decoding params: %s", err)
-		err = respondWithError(w, http.StatusInternalServerError, "Internal Server Error: "+strconv.Itoa(http.StatusInternalServerError))
-		if err != nil {
-			log.Printf("Error sending error response: %s", err)
-			return
-		}
+		respondWithError(w, r, errInvalidJSON())
 		return
 	}
 
-	tokenHeader, err := auth.GetBearerToken(r.Header.Clone())
-	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+	tokenID, ok := userIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, r, errUnauthorized())
+		return
 	}
 
-	tokenID, err := auth.ValidateJWT(tokenHeader, cfg.secret)
+	validated, err := cfg.validateChirp(params.Body)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid token")
+		respondWithError(w, r, err)
 		return
 	}
 
-	if len(params.Body) > 140 {
-		err = respondWithError(w, http.StatusBadRequest, "Chirp is too long")
-		if err != nil {
-			log.Printf("Error sending error response: %s", err)
-		}
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("starting transaction: %w", err)))
 		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
 
-	chirp, err := cfg.db.CreateChirp(context.Background(), database.CreateChirpParams{
-		Body:   params.Body,
-		UserID: tokenID,
+	chirp, err := qtx.CreateChirp(ctx, database.CreateChirpParams{
+		Body:    validated.Body,
+		UserID:  tokenID,
+		Flagged: validated.Flagged,
 	})
+	// The author was deleted after the token was issued.
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, errUnauthorized())
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("creating chirp: %w", err)))
+		return
+	}
 
-	res := Response{
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
-		Id:        chirp.ID,
+	err = saveMentions(ctx, qtx, chirp)
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("saving mentions of chirp %s: %w", chirp.ID, err)))
+		return
 	}
 
-	err = respondWithJSON(w, http.StatusCreated, res)
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("commiting chirp: %w", err)))
+		return
+	}
+	cfg.metrics.chirpsCreated.Inc()
+	if chirp.Flagged {
+		slog.WarnContext(r.Context(), "Chirp flagged for review", "chirp_id", chirp.ID, "words", validated.Words)
+	}
+
+	err = respondWithJSON(w, http.StatusCreated, chirpFromDB(chirp))
 	if err != nil {
-		log.Printf("Error sending response: %s", err)
+		slog.ErrorContext(r.Context(), "Error sending response", "error", err)
 	}
 }
 
 func handleCreateUser(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
 	type Response struct {
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-		Email     string    `json:"email"`
-		Id        uuid.UUID `json:"id"`
+		CreatedAt   time.Time `json:"created_at"`
+		UpdatedAt   time.Time `json:"updated_at"`
+		Email       string    `json:"email"`
+		Id          uuid.UUID `json:"id"`
+		IsChirpyRed bool      `json:"is_chirpy_red"`
 	}
 
 	type Parameters struct {
@@ -434,51 +1792,48 @@ func handleCreateUser(w http.ResponseWriter, r *http.Request, cfg *apiConfig) {
 	params := Parameters{}
 	err := decoder.Decode(&params)
 	if err != nil {
-		log.Printf("Error decoding params: %s", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithError(w, r, errInvalidJSON())
 		return
 	}
 
-	if params.Password == "" || len(params.Password) < 5 {
-		err = respondWithError(w, http.StatusBadRequest, "Wrong password lenght")
-		if err != nil {
-			log.Printf("Error sending error response: %s", err)
-			return
-		}
+	if len(params.Password) < MIN_PASSWORD_LENGTH {
+		respondWithError(w, r, newAPIError(
+			http.StatusBadRequest,
+			CODE_PASSWORD_TOO_SHORT,
+			fmt.Sprintf("Password must be at least %d characters", MIN_PASSWORD_LENGTH),
+		).withDetails(map[string]any{"min_length": MIN_PASSWORD_LENGTH}))
 		return
 	}
 
 	hashed, err := auth.HashedPassword(params.Password)
 	if err != nil {
-		log.Printf("Error hashing password: %s", err)
-		err = respondWithError(w, http.StatusInternalServerError, "While hashing password")
-		if err != nil {
-			log.Printf("Error sending error response: %s", err)
-		}
+		respondWithError(w, r, errInternal(fmt.Errorf("hashing password: %w", err)))
 		return
 	}
 
-	user, err := cfg.db.CreateUser(context.Background(), database.CreateUserParams{
+	user, err := cfg.db.CreateUser(ctx, database.CreateUserParams{
 		Email:          params.Email,
 		HashedPassowrd: hashed,
 	})
+	if isUniqueViolation(err, "users_email_key") {
+		respondWithError(w, r, newAPIError(http.StatusConflict, CODE_ALREADY_EXISTS, "Email is already taken"))
+		return
+	}
 	if err != nil {
-		log.Printf("Error creating user: %s", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithError(w, r, errDatabase(ctx, fmt.Errorf("creating user: %w", err)))
 		return
 	}
 
 	res := Response{
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
-		Email:     user.Email,
-		Id:        user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		Id:          user.ID,
+		IsChirpyRed: user.IsChirpyRed,
 	}
 
 	err = respondWithJSON(w, http.StatusCreated, res)
 	if err != nil {
-		log.Printf("Error sending json: %s", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		respondWithError(w, r, errInternal(fmt.Errorf("sending response: %w", err)))
 	}
 }
@@ -0,0 +1,506 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SzymonJaroslawski/chirpy/internal/auth"
+	"github.com/SzymonJaroslawski/chirpy/internal/database"
+	"github.com/SzymonJaroslawski/chirpy/internal/filter"
+	"github.com/google/uuid"
+)
+
+func TestHandleValidateChirp(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        filter.Mode
+		body        string
+		wantStatus  int
+		wantCleaned string
+		wantFlagged bool
+		wantCode    string
+	}{
+		{name: "Clean chirp", mode: filter.ModeMask, body: "Hello, world!", wantStatus: http.StatusOK, wantCleaned: "Hello, world!"},
+		{name: "Mask", mode: filter.ModeMask, body: "What a Kerfuffle!", wantStatus: http.StatusOK, wantCleaned: "What a *********!"},
+		{name: "Reject", mode: filter.ModeReject, body: "What a kerfuffle", wantStatus: http.StatusUnprocessableEntity, wantCode: CODE_PROFANITY},
+		{name: "Flag", mode: filter.ModeFlag, body: "What a kerfuffle", wantStatus: http.StatusOK, wantCleaned: "What a kerfuffle", wantFlagged: true},
+		{name: "Too long", mode: filter.ModeMask, body: strings.Repeat("a", 141), wantStatus: http.StatusBadRequest, wantCode: CODE_CHIRP_TOO_LONG},
+		{name: "Invalid JSON", mode: filter.ModeMask, wantStatus: http.StatusBadRequest, wantCode: CODE_INVALID_JSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestAPIConfig()
+			cfg.profanityMode = tt.mode
+
+			reqBody := "{"
+			if tt.wantCode != CODE_INVALID_JSON {
+				encoded, _ := json.Marshal(map[string]string{"body": tt.body})
+				reqBody = string(encoded)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/api/validate_chirp", strings.NewReader(reqBody))
+			rec := httptest.NewRecorder()
+			handleValidateChirp(rec, req, cfg)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+
+			var res struct {
+				CleanedBody string `json:"cleaned_body"`
+				Flagged     bool   `json:"flagged"`
+				Code        string `json:"code"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			if res.CleanedBody != tt.wantCleaned || res.Flagged != tt.wantFlagged || res.Code != tt.wantCode {
+				t.Errorf("response = %+v, want cleaned %q, flagged %v, code %q", res, tt.wantCleaned, tt.wantFlagged, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestHandleUpdateChirp(t *testing.T) {
+	tests := []struct {
+		name          string
+		chirpID       string
+		byOther       bool
+		body          string
+		wantStatus    int
+		wantCode      string
+		wantBody      string
+		wantRevisions []string
+	}{
+		{name: "Edit", body: `{"body": "hello, world"}`, wantStatus: http.StatusOK, wantBody: "hello, world", wantRevisions: []string{"hello"}},
+		{name: "Same body", body: `{"body": "hello"}`, wantStatus: http.StatusOK, wantBody: "hello"},
+		{name: "Not the author", byOther: true, body: `{"body": "hijacked"}`, wantStatus: http.StatusForbidden, wantCode: CODE_FORBIDDEN, wantBody: "hello"},
+		{name: "Unknown chirp", chirpID: uuid.NewString(), body: `{"body": "hi"}`, wantStatus: http.StatusNotFound, wantCode: CODE_NOT_FOUND, wantBody: "hello"},
+		{name: "Invalid chirp ID", chirpID: "not-a-uuid", body: `{"body": "hi"}`, wantStatus: http.StatusBadRequest, wantCode: CODE_INVALID_PARAMETER, wantBody: "hello"},
+		{name: "Invalid JSON", body: `{`, wantStatus: http.StatusBadRequest, wantCode: CODE_INVALID_JSON, wantBody: "hello"},
+		{name: "Empty body", body: `{"body": "  "}`, wantStatus: http.StatusBadRequest, wantCode: CODE_EMPTY_CHIRP, wantBody: "hello"},
+		{name: "Too long", body: `{"body": "` + strings.Repeat("a", 141) + `"}`, wantStatus: http.StatusBadRequest, wantCode: CODE_CHIRP_TOO_LONG, wantBody: "hello"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestAPIConfig()
+			db := newFakeDB(t, cfg)
+			author := db.addUser(database.User{})
+			other := db.addUser(database.User{})
+			chirp := db.addChirp(database.Chirp{UserID: author.ID, Body: "hello"})
+
+			chirpID := tt.chirpID
+			if chirpID == "" {
+				chirpID = chirp.ID.String()
+			}
+			userID := author.ID
+			if tt.byOther {
+				userID = other.ID
+			}
+
+			req := httptest.NewRequest(http.MethodPut, "/api/chirps/"+chirpID, strings.NewReader(tt.body))
+			req.SetPathValue("chirpID", chirpID)
+			req = req.WithContext(context.WithValue(req.Context(), userIDContextKey, userID))
+			rec := httptest.NewRecorder()
+			handleUpdateChirp(rec, req, cfg)
+
+			var res problem
+			json.Unmarshal(rec.Body.Bytes(), &res)
+			if rec.Code != tt.wantStatus || res.Code != tt.wantCode {
+				t.Fatalf("response = %d %q, want %d %q", rec.Code, res.Code, tt.wantStatus, tt.wantCode)
+			}
+
+			stored, _ := db.getChirp(chirp.ID)
+			if stored.Body != tt.wantBody {
+				t.Errorf("stored body = %q, want %q", stored.Body, tt.wantBody)
+			}
+			if stored.EditedAt.Valid != (len(tt.wantRevisions) > 0) {
+				t.Errorf("stored edited_at = %v, want set only after an edit", stored.EditedAt)
+			}
+			if revisions := db.revisionBodies(chirp.ID); !slices.Equal(revisions, tt.wantRevisions) {
+				t.Errorf("revisions = %q, want %q", revisions, tt.wantRevisions)
+			}
+
+			if rec.Code == http.StatusOK {
+				var got Chirp
+				json.Unmarshal(rec.Body.Bytes(), &got)
+				if got.Body != tt.wantBody || got.Edited != (len(tt.wantRevisions) > 0) {
+					t.Errorf("response = %+v, want body %q", got, tt.wantBody)
+				}
+			}
+		})
+	}
+}
+
+func TestChirpFromDBEdited(t *testing.T) {
+	chirp := database.Chirp{ID: uuid.New(), Body: "hello"}
+	if chirpFromDB(chirp).Edited {
+		t.Error("chirpFromDB().Edited = true for a chirp that was never edited")
+	}
+
+	chirp.EditedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	if !chirpFromDB(chirp).Edited {
+		t.Error("chirpFromDB().Edited = false for an edited chirp")
+	}
+}
+
+func TestAdminModerationInvalidID(t *testing.T) {
+	tests := []struct {
+		name    string
+		param   string
+		handler func(http.ResponseWriter, *http.Request, *apiConfig)
+	}{
+		{name: "Delete user", param: "userID", handler: handleAdminDeleteUser},
+		{name: "Restore user", param: "userID", handler: handleAdminRestoreUser},
+		{name: "Delete chirp", param: "chirpID", handler: handleAdminDeleteChirp},
+		{name: "Restore chirp", param: "chirpID", handler: handleAdminRestoreChirp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestAPIConfig()
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/", nil)
+			req.SetPathValue(tt.param, "not-a-uuid")
+			rec := httptest.NewRecorder()
+			tt.handler(rec, req, cfg)
+
+			var res problem
+			json.Unmarshal(rec.Body.Bytes(), &res)
+			if rec.Code != http.StatusBadRequest || res.Code != CODE_INVALID_PARAMETER {
+				t.Errorf("response = %d %q, want %d %q", rec.Code, res.Code, http.StatusBadRequest, CODE_INVALID_PARAMETER)
+			}
+			if res.Details["parameter"] != tt.param {
+				t.Errorf("details = %v, want parameter %q", res.Details, tt.param)
+			}
+		})
+	}
+}
+
+func TestHandleDeleteUser(t *testing.T) {
+	hash, err := auth.HashedPassword("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		loggedIn    bool
+		body        string
+		wantStatus  int
+		wantCode    string
+		wantDeleted bool
+	}{
+		{name: "Not logged in", body: `{"password": "hunter2"}`, wantStatus: http.StatusUnauthorized, wantCode: CODE_UNAUTHORIZED},
+		{name: "Invalid JSON", loggedIn: true, body: `{`, wantStatus: http.StatusBadRequest, wantCode: CODE_INVALID_JSON},
+		{name: "Wrong password", loggedIn: true, body: `{"password": "hunter3"}`, wantStatus: http.StatusUnauthorized, wantCode: CODE_INVALID_CREDENTIALS},
+		{name: "Deleted", loggedIn: true, body: `{"password": "hunter2"}`, wantStatus: http.StatusNoContent, wantDeleted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestAPIConfig()
+			db := newFakeDB(t, cfg)
+			user := db.addUser(database.User{Email: "jane@example.com", HashedPassowrd: hash})
+			other := db.addUser(database.User{Email: "john@example.com"})
+			ownChirp := db.addChirp(database.Chirp{UserID: user.ID, Body: "mine"})
+			otherChirp := db.addChirp(database.Chirp{UserID: other.ID, Body: "theirs"})
+			db.addLike(otherChirp.ID, user.ID)
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/users", strings.NewReader(tt.body))
+			if tt.loggedIn {
+				req = req.WithContext(context.WithValue(req.Context(), userIDContextKey, user.ID))
+			}
+			rec := httptest.NewRecorder()
+			handleDeleteUser(rec, req, cfg)
+
+			var res problem
+			json.Unmarshal(rec.Body.Bytes(), &res)
+			if rec.Code != tt.wantStatus || res.Code != tt.wantCode {
+				t.Fatalf("response = %d %q, want %d %q", rec.Code, res.Code, tt.wantStatus, tt.wantCode)
+			}
+
+			// Nothing restorable is left behind.
+			if kept := db.hasUser(user.ID); kept == tt.wantDeleted {
+				t.Errorf("user row kept = %v, want %v", kept, !tt.wantDeleted)
+			}
+			if _, ok := db.getChirp(ownChirp.ID); ok == tt.wantDeleted {
+				t.Errorf("chirp kept = %v, want %v", ok, !tt.wantDeleted)
+			}
+
+			wantLikes := int32(1)
+			if tt.wantDeleted {
+				wantLikes = 0
+			}
+			if chirp, _ := db.getChirp(otherChirp.ID); chirp.LikeCount != wantLikes {
+				t.Errorf("like count of liked chirp = %d, want %d", chirp.LikeCount, wantLikes)
+			}
+		})
+	}
+}
+
+func TestProfileFromDBPrivateFields(t *testing.T) {
+	user := database.User{
+		ID:             uuid.New(),
+		Email:          "jane@example.com",
+		HashedPassowrd: "$2a$10$hash",
+		Handle:         sql.NullString{String: "jane", Valid: true},
+	}
+
+	public, _ := json.Marshal(profileFromDB(user, 3))
+	for _, private := range []string{user.Email, user.HashedPassowrd, "email", "is_chirpy_red"} {
+		if strings.Contains(string(public), private) {
+			t.Errorf("public profile %s contains %q", public, private)
+		}
+	}
+
+	own, _ := json.Marshal(ownProfileFromDB(user, 3))
+	if !strings.Contains(string(own), user.Email) || strings.Contains(string(own), user.HashedPassowrd) {
+		t.Errorf("own profile = %s, want the email and no password hash", own)
+	}
+	if !strings.Contains(string(own), `"display_name":null`) || !strings.Contains(string(own), `"chirp_count":3`) {
+		t.Errorf("own profile = %s, want null display_name and chirp_count 3", own)
+	}
+}
+
+func TestFollowUnfollow(t *testing.T) {
+	cfg := newTestAPIConfig()
+	db := newFakeDB(t, cfg)
+	alice := db.addUser(database.User{Handle: sql.NullString{String: "alice", Valid: true}})
+	bob := db.addUser(database.User{})
+
+	steps := []struct {
+		name          string
+		handler       func(http.ResponseWriter, *http.Request, *apiConfig)
+		userID        string
+		wantStatus    int
+		wantFollowers []uuid.UUID
+	}{
+		{name: "Follow", handler: handleFollowUser, userID: bob.ID.String(), wantStatus: http.StatusNoContent, wantFollowers: []uuid.UUID{alice.ID}},
+		{name: "Follow again", handler: handleFollowUser, userID: bob.ID.String(), wantStatus: http.StatusNoContent, wantFollowers: []uuid.UUID{alice.ID}},
+		{name: "Follow yourself", handler: handleFollowUser, userID: alice.ID.String(), wantStatus: http.StatusBadRequest, wantFollowers: []uuid.UUID{alice.ID}},
+		{name: "Follow unknown user", handler: handleFollowUser, userID: uuid.NewString(), wantStatus: http.StatusNotFound, wantFollowers: []uuid.UUID{alice.ID}},
+		{name: "Follow invalid user ID", handler: handleFollowUser, userID: "not-a-uuid", wantStatus: http.StatusBadRequest, wantFollowers: []uuid.UUID{alice.ID}},
+		{name: "Unfollow", handler: handleUnfollowUser, userID: bob.ID.String(), wantStatus: http.StatusNoContent},
+		{name: "Unfollow again", handler: handleUnfollowUser, userID: bob.ID.String(), wantStatus: http.StatusNoContent},
+	}
+
+	for _, step := range steps {
+		req := httptest.NewRequest(http.MethodPost, "/api/users/"+step.userID+"/follow", nil)
+		req.SetPathValue("userID", step.userID)
+		req = req.WithContext(context.WithValue(req.Context(), userIDContextKey, alice.ID))
+		rec := httptest.NewRecorder()
+		step.handler(rec, req, cfg)
+
+		if rec.Code != step.wantStatus {
+			t.Fatalf("%s: status = %d, want %d: %s", step.name, rec.Code, step.wantStatus, rec.Body.String())
+		}
+
+		followers := listFollowUsers(t, cfg, handleGetFollowers, bob.ID)
+		if !slices.Equal(followers, step.wantFollowers) {
+			t.Errorf("%s: followers of bob = %v, want %v", step.name, followers, step.wantFollowers)
+		}
+
+		var wantFollowing []uuid.UUID
+		if len(step.wantFollowers) > 0 {
+			wantFollowing = []uuid.UUID{bob.ID}
+		}
+		following := listFollowUsers(t, cfg, handleGetFollowing, alice.ID)
+		if !slices.Equal(following, wantFollowing) {
+			t.Errorf("%s: alice following = %v, want %v", step.name, following, wantFollowing)
+		}
+	}
+}
+
+// listFollowUsers returns the IDs on the first page of a follow list.
+func listFollowUsers(t *testing.T, cfg *apiConfig, handler func(http.ResponseWriter, *http.Request, *apiConfig), userID uuid.UUID) []uuid.UUID {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users/"+userID.String()+"/followers", nil)
+	req.SetPathValue("userID", userID.String())
+	rec := httptest.NewRecorder()
+	handler(rec, req, cfg)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("listing follows: status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var res struct {
+		Users []FollowUser `json:"users"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &res)
+
+	var ids []uuid.UUID
+	for _, user := range res.Users {
+		ids = append(ids, user.Id)
+	}
+	return ids
+}
+
+func TestHandleGetTimeline(t *testing.T) {
+	cfg := newTestAPIConfig()
+	db := newFakeDB(t, cfg)
+	alice := db.addUser(database.User{})
+	bob := db.addUser(database.User{})
+	carol := db.addUser(database.User{})
+	dave := db.addUser(database.User{})
+	db.addFollow(alice.ID, bob.ID)
+	db.addFollow(alice.ID, carol.ID)
+
+	start := time.Date(2024, 12, 1, 12, 0, 0, 0, time.UTC)
+	chirpAt := func(author uuid.UUID, minutes int) database.Chirp {
+		at := start.Add(time.Duration(minutes) * time.Minute)
+		return db.addChirp(database.Chirp{UserID: author, Body: "chirp", CreatedAt: at, UpdatedAt: at})
+	}
+	bob1 := chirpAt(bob.ID, 1)
+	carol2 := chirpAt(carol.ID, 2)
+	chirpAt(dave.ID, 3)
+	chirpAt(alice.ID, 4)
+	bob5 := chirpAt(bob.ID, 5)
+	db.addChirp(database.Chirp{UserID: carol.ID, Body: "deleted", CreatedAt: start.Add(6 * time.Minute), DeletedAt: sql.NullTime{Time: start, Valid: true}})
+	carol7 := chirpAt(carol.ID, 7)
+
+	// Followed authors only, newest first, across pages of two.
+	want := []uuid.UUID{carol7.ID, bob5.ID, carol2.ID, bob1.ID}
+
+	var got []uuid.UUID
+	cursor := ""
+	for page := 0; page < 5; page++ {
+		req := httptest.NewRequest(http.MethodGet, "/api/timeline?limit=2&cursor="+cursor, nil)
+		req = req.WithContext(context.WithValue(req.Context(), userIDContextKey, alice.ID))
+		rec := httptest.NewRecorder()
+		handleGetTimeline(rec, req, cfg)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("page %d: status = %d: %s", page, rec.Code, rec.Body.String())
+		}
+
+		var res struct {
+			Chirps     []Chirp `json:"chirps"`
+			NextCursor string  `json:"next_cursor"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		for _, chirp := range res.Chirps {
+			got = append(got, chirp.Id)
+		}
+
+		cursor = res.NextCursor
+		if cursor == "" {
+			break
+		}
+	}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("timeline = %v, want %v", got, want)
+	}
+}
+
+// The steps run in order against one database, and like_count has to match
+// the likes stored after every one of them.
+func TestLikeUnlike(t *testing.T) {
+	cfg := newTestAPIConfig()
+	db := newFakeDB(t, cfg)
+	alice := db.addUser(database.User{})
+	bob := db.addUser(database.User{})
+	chirp := db.addChirp(database.Chirp{UserID: bob.ID, Body: "like me"})
+
+	steps := []struct {
+		name       string
+		handler    func(http.ResponseWriter, *http.Request, *apiConfig)
+		userID     uuid.UUID
+		chirpID    string
+		wantStatus int
+		wantLikes  int32
+		wantLiked  bool
+	}{
+		{name: "Like", handler: handleLikeChirp, userID: alice.ID, chirpID: chirp.ID.String(), wantStatus: http.StatusOK, wantLikes: 1, wantLiked: true},
+		{name: "Like again", handler: handleLikeChirp, userID: alice.ID, chirpID: chirp.ID.String(), wantStatus: http.StatusOK, wantLikes: 1, wantLiked: true},
+		{name: "Like by another user", handler: handleLikeChirp, userID: bob.ID, chirpID: chirp.ID.String(), wantStatus: http.StatusOK, wantLikes: 2, wantLiked: true},
+		{name: "Unlike", handler: handleUnlikeChirp, userID: alice.ID, chirpID: chirp.ID.String(), wantStatus: http.StatusOK, wantLikes: 1},
+		{name: "Unlike again", handler: handleUnlikeChirp, userID: alice.ID, chirpID: chirp.ID.String(), wantStatus: http.StatusOK, wantLikes: 1},
+		{name: "Like without login", handler: handleLikeChirp, chirpID: chirp.ID.String(), wantStatus: http.StatusUnauthorized, wantLikes: 1},
+		{name: "Like unknown chirp", handler: handleLikeChirp, userID: alice.ID, chirpID: uuid.NewString(), wantStatus: http.StatusNotFound, wantLikes: 1},
+		{name: "Like invalid chirp ID", handler: handleLikeChirp, userID: alice.ID, chirpID: "not-a-uuid", wantStatus: http.StatusBadRequest, wantLikes: 1},
+	}
+
+	for _, step := range steps {
+		req := httptest.NewRequest(http.MethodPost, "/api/chirps/"+step.chirpID+"/like", nil)
+		req.SetPathValue("chirpID", step.chirpID)
+		if step.userID != uuid.Nil {
+			req = req.WithContext(context.WithValue(req.Context(), userIDContextKey, step.userID))
+		}
+		rec := httptest.NewRecorder()
+		step.handler(rec, req, cfg)
+
+		if rec.Code != step.wantStatus {
+			t.Fatalf("%s: status = %d, want %d: %s", step.name, rec.Code, step.wantStatus, rec.Body.String())
+		}
+		if rec.Code == http.StatusOK {
+			var res Chirp
+			json.Unmarshal(rec.Body.Bytes(), &res)
+			if res.LikeCount != step.wantLikes || res.LikedByMe != step.wantLiked {
+				t.Errorf("%s: response = %d likes, liked %v, want %d, %v", step.name, res.LikeCount, res.LikedByMe, step.wantLikes, step.wantLiked)
+			}
+		}
+
+		stored, _ := db.getChirp(chirp.ID)
+		if stored.LikeCount != step.wantLikes || db.likeCount(chirp.ID) != step.wantLikes {
+			t.Errorf("%s: like_count = %d with %d likes stored, want %d", step.name, stored.LikeCount, db.likeCount(chirp.ID), step.wantLikes)
+		}
+	}
+}
+
+func TestChirpFromDBLikes(t *testing.T) {
+	chirp := chirpFromDB(database.Chirp{ID: uuid.New(), LikeCount: 7})
+	if chirp.LikeCount != 7 || chirp.LikedByMe {
+		t.Errorf("chirpFromDB() = %+v, want 7 likes and not liked", chirp)
+	}
+
+	// Anonymous callers never reach the database.
+	chirps := []Chirp{chirp}
+	err := markLiked(context.Background(), nil, uuid.Nil, chirps)
+	if err != nil || chirps[0].LikedByMe {
+		t.Errorf("markLiked() for anonymous caller = %v, liked %v", err, chirps[0].LikedByMe)
+	}
+}
+
+func TestHandlePolkaWebhookAPIKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		polkaKey   string
+		header     string
+		wantStatus int
+	}{
+		{name: "Valid key", polkaKey: "polka-key", header: "ApiKey polka-key", wantStatus: http.StatusNoContent},
+		{name: "Wrong key", polkaKey: "polka-key", header: "ApiKey polka-kez", wantStatus: http.StatusUnauthorized},
+		{name: "Key prefix", polkaKey: "polka-key", header: "ApiKey polka", wantStatus: http.StatusUnauthorized},
+		{name: "Missing header", polkaKey: "polka-key", wantStatus: http.StatusUnauthorized},
+		{name: "No key configured", header: "ApiKey ", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestAPIConfig()
+			cfg.polkaKey = tt.polkaKey
+
+			// Events other than user.upgraded are acknowledged without
+			// touching the database.
+			req := httptest.NewRequest(http.MethodPost, "/api/polka/webhooks", strings.NewReader(`{"event": "user.downgraded"}`))
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handlePolkaWebhook(rec, req, cfg)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
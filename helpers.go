@@ -1,11 +1,113 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
-	"strings"
+
+	"github.com/SzymonJaroslawski/chirpy/internal/auth"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+// STATUS_CLIENT_CLOSED_REQUEST is the non-standard code nginx uses when the
+// client goes away before the response is written.
+const STATUS_CLIENT_CLOSED_REQUEST = 499
+
+// dbContext bounds the database work of a request by both the request's own
+// lifetime and the configured per-request deadline.
+func (cfg *apiConfig) dbContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context(), cfg.dbTimeout)
+}
+
+// dbErrorStatus maps a failed query to a status code. Queries stopped by the
+// deadline are reported as 503, ones stopped because the client disconnected
+// as 499, and everything else as 500.
+func dbErrorStatus(ctx context.Context, err error) int {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable
+	case errors.Is(ctx.Err(), context.Canceled), errors.Is(err, context.Canceled):
+		return STATUS_CLIENT_CLOSED_REQUEST
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (cfg *apiConfig) makeAccessToken(userID uuid.UUID) (string, error) {
+	opts := auth.TokenOptions{}
+	if cfg.jwtAudience != "" {
+		opts.Audience = []string{cfg.jwtAudience}
+	}
+	return auth.MakeToken(userID, cfg.keys, cfg.accessTokenTTL, opts)
+}
+
+func (cfg *apiConfig) validateAccessToken(token string) (uuid.UUID, error) {
+	return auth.ValidateToken(token, cfg.keys, auth.ValidationOptions{
+		TokenType:  auth.TokenTypeAccess,
+		Audience:   cfg.jwtAudience,
+		Algorithms: cfg.jwtAlgorithms,
+		Leeway:     cfg.jwtLeeway,
+	})
+}
+
+// userIsActive reports whether the user an access token was issued to still
+// exists. Tokens outlive the account, so every authenticated request checks.
+func (cfg *apiConfig) userIsActive(r *http.Request, userID uuid.UUID) (bool, error) {
+	ctx, cancel := cfg.dbContext(r)
+	defer cancel()
+
+	active, err := cfg.db.UserIsActive(ctx, userID)
+	if err != nil {
+		return false, errDatabase(ctx, fmt.Errorf("checking user %s: %w", userID, err))
+	}
+	return active, nil
+}
+
+// bannedWords reads the word list managed through /admin/banned-words.
+func (cfg *apiConfig) bannedWords(ctx context.Context) ([]string, error) {
+	rows, err := cfg.db.ListBannedWords(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	words := make([]string, 0, len(rows))
+	for _, row := range rows {
+		words = append(words, row.Word)
+	}
+	return words, nil
+}
+
+// reloadBannedWords refreshes the filter after the word list changed. Only
+// this process sees the change right away; other replicas pick it up on their
+// next reloadProfanity tick. The change is already stored, so a failed reload
+// is only logged and the next successful one picks it up.
+func (cfg *apiConfig) reloadBannedWords(ctx context.Context) {
+	err := cfg.profanity.Reload(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error reloading profane words", "error", err)
+	}
+}
+
+// isUniqueViolation reports whether err is postgres rejecting a duplicate in
+// the named unique index or constraint.
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
+
+// nullStringPtr turns NULL into nil so it is sent as JSON null.
+func nullStringPtr(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
+
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) error {
 	response, err := json.Marshal(payload)
 	if err != nil {
@@ -17,21 +119,3 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) error
 	w.Write(response)
 	return nil
 }
-
-func respondWithError(w http.ResponseWriter, code int, msg string) error {
-	return respondWithJSON(w, code, map[string]string{"error": msg})
-}
-
-func validateProfaneLogic(s string, profane []string) string {
-	words := strings.Split(s, " ")
-	for i, word := range words {
-		loweredWord := strings.ToLower(word)
-		for _, profane_word := range profane {
-			if strings.Contains(loweredWord, profane_word) {
-				words[i] = "****"
-			}
-		}
-	}
-
-	return strings.Join(words, " ")
-}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestDBErrorStatus(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithTimeout(context.Background(), -time.Second)
+	defer cancelExpired()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want int
+	}{
+		{name: "Query error", ctx: context.Background(), err: errors.New("syntax error"), want: http.StatusInternalServerError},
+		{name: "Deadline exceeded", ctx: expired, err: errors.New("pq: canceling statement due to user request"), want: http.StatusServiceUnavailable},
+		{name: "Client disconnected", ctx: canceled, err: context.Canceled, want: STATUS_CLIENT_CLOSED_REQUEST},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dbErrorStatus(tt.ctx, tt.err); got != tt.want {
+				t.Errorf("dbErrorStatus() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "Handle taken", err: fmt.Errorf("updating profile: %w", &pq.Error{Code: "23505", Constraint: "users_handle_lower_idx"}), want: true},
+		{name: "Other index", err: &pq.Error{Code: "23505", Constraint: "users_email_key"}, want: false},
+		{name: "Other error code", err: &pq.Error{Code: "23503", Constraint: "users_handle_lower_idx"}, want: false},
+		{name: "Not a postgres error", err: errors.New("users_handle_lower_idx"), want: false},
+		{name: "No error", err: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isUniqueViolation(tt.err, "users_handle_lower_idx"); got != tt.want {
+				t.Errorf("isUniqueViolation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,10 +1,18 @@
 package auth
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -154,3 +162,335 @@ func TestGetBearerToken(t *testing.T) {
 		})
 	}
 }
+
+func TestGetAPIKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers http.Header
+		wantKey string
+		wantErr bool
+	}{
+		{
+			name: "Valid ApiKey",
+			headers: http.Header{
+				"Authorization": []string{"ApiKey valid_key"},
+			},
+			wantKey: "valid_key",
+			wantErr: false,
+		},
+		{
+			name:    "Missing Authorization header",
+			headers: http.Header{},
+			wantKey: "",
+			wantErr: true,
+		},
+		{
+			name: "Bearer instead of ApiKey",
+			headers: http.Header{
+				"Authorization": []string{"Bearer valid_key"},
+			},
+			wantKey: "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotKey, err := GetAPIKey(tt.headers)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetAPIKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotKey != tt.wantKey {
+				t.Errorf("GetAPIKey() gotKey = %v, want %v", gotKey, tt.wantKey)
+			}
+		})
+	}
+}
+
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func loadTestKey(t *testing.T, kid string, privateKey any) *SigningKey {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := LoadKeyFromPEM(writePEM(t, "PRIVATE KEY", der), kid)
+	if err != nil {
+		t.Fatalf("LoadKeyFromPEM() error = %v", err)
+	}
+	return key
+}
+
+func TestValidateJWTWithKeys(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	_, otherEdKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	rsaSigningKey := loadTestKey(t, "rsa-1", rsaKey)
+	edSigningKey := loadTestKey(t, "ed-1", edKey)
+	otherSigningKey := loadTestKey(t, "ed-1", otherEdKey)
+
+	// The old key is only needed to verify, so load it from its public half.
+	publicDER, _ := x509.MarshalPKIXPublicKey(rsaKey.Public())
+	rsaVerifyKey, err := LoadKeyFromPEM(writePEM(t, "PUBLIC KEY", publicDER), "rsa-1")
+	if err != nil {
+		t.Fatalf("LoadKeyFromPEM() error = %v", err)
+	}
+
+	rsaSet, _ := NewKeySet(rsaSigningKey)
+	edSet, _ := NewKeySet(edSigningKey)
+	rotatedSet, _ := NewKeySet(edSigningKey, rsaVerifyKey)
+	otherSet, _ := NewKeySet(otherSigningKey)
+
+	userID := uuid.New()
+	rsaToken, _ := MakeJWTWithKeys(userID, rsaSet, time.Hour)
+	edToken, _ := MakeJWTWithKeys(userID, edSet, time.Hour)
+	hmacToken, _ := MakeJWT(userID, "secret", time.Hour)
+
+	tests := []struct {
+		name        string
+		tokenString string
+		keys        KeyProvider
+		wantUserID  uuid.UUID
+		wantErr     bool
+	}{
+		{
+			name:        "RS256 token",
+			tokenString: rsaToken,
+			keys:        rsaSet,
+			wantUserID:  userID,
+		},
+		{
+			name:        "EdDSA token",
+			tokenString: edToken,
+			keys:        edSet,
+			wantUserID:  userID,
+		},
+		{
+			name:        "Old key accepted after rotation",
+			tokenString: rsaToken,
+			keys:        rotatedSet,
+			wantUserID:  userID,
+		},
+		{
+			name:        "New key accepted after rotation",
+			tokenString: edToken,
+			keys:        rotatedSet,
+			wantUserID:  userID,
+		},
+		{
+			name:        "Unknown kid",
+			tokenString: rsaToken,
+			keys:        edSet,
+			wantErr:     true,
+		},
+		{
+			name:        "Same kid, different key",
+			tokenString: edToken,
+			keys:        otherSet,
+			wantErr:     true,
+		},
+		{
+			name:        "HMAC token against key set",
+			tokenString: hmacToken,
+			keys:        rsaSet,
+			wantErr:     true,
+		},
+		{
+			name:        "RS256 token against HMAC secret",
+			tokenString: rsaToken,
+			keys:        NewHMACKeyProvider("secret"),
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotUserID, err := ValidateJWTWithKeys(tt.tokenString, tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateJWTWithKeys() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotUserID != tt.wantUserID {
+				t.Errorf("ValidateJWTWithKeys() gotUserID = %v, want %v", gotUserID, tt.wantUserID)
+			}
+		})
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	set, err := NewKeySet(loadTestKey(t, "ed-1", edKey), loadTestKey(t, "rsa-1", rsaKey))
+	if err != nil {
+		t.Fatalf("NewKeySet() error = %v", err)
+	}
+
+	jwks := JWKS(set)
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("JWKS() got %d keys, want 2", len(jwks.Keys))
+	}
+	if k := jwks.Keys[0]; k.Kid != "ed-1" || k.Kty != "OKP" || k.Alg != "EdDSA" || k.X == "" {
+		t.Errorf("JWKS() Ed25519 key = %+v", k)
+	}
+	if k := jwks.Keys[1]; k.Kid != "rsa-1" || k.Kty != "RSA" || k.Alg != "RS256" || k.E != "AQAB" {
+		t.Errorf("JWKS() RSA key = %+v", k)
+	}
+
+	if got := JWKS(NewHMACKeyProvider("secret")); len(got.Keys) != 0 {
+		t.Errorf("JWKS() published %d HMAC keys", len(got.Keys))
+	}
+}
+
+func TestValidateToken(t *testing.T) {
+	keys := NewHMACKeyProvider("secret")
+	userID := uuid.New()
+
+	accessToken, _ := MakeToken(userID, keys, time.Hour, TokenOptions{})
+	audienceToken, _ := MakeToken(userID, keys, time.Hour, TokenOptions{Audience: []string{"chirpy-api"}})
+	resetToken, _ := MakeToken(userID, keys, time.Hour, TokenOptions{Type: TokenTypePasswordReset})
+	verifyToken, _ := MakeToken(userID, keys, time.Hour, TokenOptions{Type: TokenTypeEmailVerification})
+	justExpiredToken, _ := MakeToken(userID, keys, -10*time.Second, TokenOptions{})
+	expiredToken, _ := MakeToken(userID, keys, -time.Hour, TokenOptions{})
+
+	signWith := func(method jwt.SigningMethod, claims jwt.RegisteredClaims) string {
+		token, _ := jwt.NewWithClaims(method, claims).SignedString([]byte("secret"))
+		return token
+	}
+	now := time.Now().UTC()
+	validClaims := jwt.RegisteredClaims{
+		Issuer:    string(TokenTypeAccess),
+		Subject:   userID.String(),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+	}
+	hs384Token := signWith(jwt.SigningMethodHS384, validClaims)
+	noExpiryClaims := validClaims
+	noExpiryClaims.ExpiresAt = nil
+	noExpiryToken := signWith(jwt.SigningMethodHS256, noExpiryClaims)
+	notYetValidClaims := validClaims
+	notYetValidClaims.NotBefore = jwt.NewNumericDate(now.Add(time.Hour))
+	notYetValidToken := signWith(jwt.SigningMethodHS256, notYetValidClaims)
+
+	tests := []struct {
+		name        string
+		tokenString string
+		opts        ValidationOptions
+		wantErr     bool
+	}{
+		{
+			name:        "Access token with default options",
+			tokenString: accessToken,
+		},
+		{
+			name:        "Expected audience present",
+			tokenString: audienceToken,
+			opts:        ValidationOptions{Audience: "chirpy-api"},
+		},
+		{
+			name:        "Expected audience missing",
+			tokenString: accessToken,
+			opts:        ValidationOptions{Audience: "chirpy-api"},
+			wantErr:     true,
+		},
+		{
+			name:        "Wrong audience",
+			tokenString: audienceToken,
+			opts:        ValidationOptions{Audience: "other-service"},
+			wantErr:     true,
+		},
+		{
+			name:        "Password reset token used as access token",
+			tokenString: resetToken,
+			wantErr:     true,
+		},
+		{
+			name:        "Password reset token",
+			tokenString: resetToken,
+			opts:        ValidationOptions{TokenType: TokenTypePasswordReset},
+		},
+		{
+			name:        "Email verification token used for password reset",
+			tokenString: verifyToken,
+			opts:        ValidationOptions{TokenType: TokenTypePasswordReset},
+			wantErr:     true,
+		},
+		{
+			name:        "Email verification token",
+			tokenString: verifyToken,
+			opts:        ValidationOptions{TokenType: TokenTypeEmailVerification},
+		},
+		{
+			name:        "Algorithm not allowed",
+			tokenString: accessToken,
+			opts:        ValidationOptions{Algorithms: []string{"RS256"}},
+			wantErr:     true,
+		},
+		{
+			name:        "Algorithm allowed",
+			tokenString: accessToken,
+			opts:        ValidationOptions{Algorithms: []string{"HS256"}},
+		},
+		{
+			name:        "Other HMAC variant with the same secret",
+			tokenString: hs384Token,
+			wantErr:     true,
+		},
+		{
+			name:        "Other HMAC variant even when allowed",
+			tokenString: hs384Token,
+			opts:        ValidationOptions{Algorithms: []string{"HS256", "HS384"}},
+			wantErr:     true,
+		},
+		{
+			name:        "Missing expiry",
+			tokenString: noExpiryToken,
+			wantErr:     true,
+		},
+		{
+			name:        "Not valid yet",
+			tokenString: notYetValidToken,
+			wantErr:     true,
+		},
+		{
+			name:        "Just expired without leeway",
+			tokenString: justExpiredToken,
+			wantErr:     true,
+		},
+		{
+			name:        "Just expired within leeway",
+			tokenString: justExpiredToken,
+			opts:        ValidationOptions{Leeway: time.Minute},
+		},
+		{
+			name:        "Expired beyond leeway",
+			tokenString: expiredToken,
+			opts:        ValidationOptions{Leeway: time.Minute},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotUserID, err := ValidateToken(tt.tokenString, keys, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateToken() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && gotUserID != userID {
+				t.Errorf("ValidateToken() gotUserID = %v, want %v", gotUserID, userID)
+			}
+		})
+	}
+}
@@ -13,22 +13,81 @@ import (
 
 type TokenType string
 
-const TokenTypeAccess TokenType = "chirpy-access"
+// The token type is carried in the issuer claim so a token minted for one
+// purpose can't be used for another.
+const (
+	TokenTypeAccess            TokenType = "chirpy-access"
+	TokenTypeEmailVerification TokenType = "chirpy-email-verification"
+	TokenTypePasswordReset     TokenType = "chirpy-password-reset"
+)
 
 var ErrNoAuthorizationIncluded = errors.New("no auth header included in request")
 
+// TokenOptions describes the token being minted. An empty Type means
+// TokenTypeAccess.
+type TokenOptions struct {
+	Type     TokenType
+	Audience []string
+}
+
+// ValidationOptions describes what a token must look like to be accepted.
+// An empty TokenType means TokenTypeAccess, an empty Audience skips the
+// audience check and empty Algorithms only allows the algorithm of the key
+// named by the token.
+type ValidationOptions struct {
+	TokenType  TokenType
+	Audience   string
+	Algorithms []string
+	Leeway     time.Duration
+}
+
 func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	key := []byte(tokenSecret)
+	return MakeJWTWithKeys(userID, NewHMACKeyProvider(tokenSecret), expiresIn)
+}
+
+func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	return ValidateJWTWithKeys(tokenString, NewHMACKeyProvider(tokenSecret))
+}
+
+func MakeJWTWithKeys(userID uuid.UUID, keys KeyProvider, expiresIn time.Duration) (string, error) {
+	return MakeToken(userID, keys, expiresIn, TokenOptions{})
+}
+
+func ValidateJWTWithKeys(tokenString string, keys KeyProvider) (uuid.UUID, error) {
+	return ValidateToken(tokenString, keys, ValidationOptions{})
+}
+
+// MakeToken signs a token with the provider's current key and records the
+// key ID in the kid header.
+func MakeToken(userID uuid.UUID, keys KeyProvider, expiresIn time.Duration, opts TokenOptions) (string, error) {
+	key, err := keys.SigningKey()
+	if err != nil {
+		return "", err
+	}
+
+	tokenType := opts.Type
+	if tokenType == "" {
+		tokenType = TokenTypeAccess
+	}
+
+	now := time.Now().UTC()
 	claims := jwt.RegisteredClaims{
-		Issuer:    string(TokenTypeAccess),
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+		Issuer:    string(tokenType),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
 		Subject:   userID.String(),
 	}
+	if len(opts.Audience) > 0 {
+		claims.Audience = opts.Audience
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token := jwt.NewWithClaims(key.Method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
 
-	signedToken, err := token.SignedString(key)
+	signedToken, err := token.SignedString(key.PrivateKey)
 	if err != nil {
 		return "", err
 	}
@@ -36,14 +95,43 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 	return signedToken, nil
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+// ValidateToken verifies a token against the key named by its kid header,
+// checks it against opts and returns the user ID from the subject.
+func ValidateToken(tokenString string, keys KeyProvider, opts ValidationOptions) (uuid.UUID, error) {
+	tokenType := opts.TokenType
+	if tokenType == "" {
+		tokenType = TokenTypeAccess
+	}
+
+	parserOptions := []jwt.ParserOption{
+		jwt.WithIssuer(string(tokenType)),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(opts.Leeway),
+	}
+	if opts.Audience != "" {
+		parserOptions = append(parserOptions, jwt.WithAudience(opts.Audience))
+	}
+	if len(opts.Algorithms) > 0 {
+		parserOptions = append(parserOptions, jwt.WithValidMethods(opts.Algorithms))
+	}
+
 	claims := jwt.RegisteredClaims{}
 	token, err := jwt.ParseWithClaims(
 		tokenString,
 		&claims,
 		func(token *jwt.Token) (interface{}, error) {
-			return []byte(tokenSecret), nil
+			kid, _ := token.Header["kid"].(string)
+			key, err := keys.VerificationKey(kid)
+			if err != nil {
+				return nil, err
+			}
+			if token.Method.Alg() != key.Method.Alg() {
+				return nil, fmt.Errorf("unexpected signing method: %s", token.Method.Alg())
+			}
+			return key.PublicKey, nil
 		},
+		parserOptions...,
 	)
 	if err != nil {
 		return uuid.Nil, err
@@ -54,15 +142,6 @@ func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
 		return uuid.Nil, err
 	}
 
-	issuer, err := token.Claims.GetIssuer()
-	if err != nil {
-		return uuid.Nil, err
-	}
-
-	if issuer != string(TokenTypeAccess) {
-		return uuid.Nil, errors.New("invalid issuer")
-	}
-
 	id, err := uuid.Parse(userIDString)
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("invalid user ID: %w", err)
@@ -84,3 +163,17 @@ func GetBearerToken(headers http.Header) (string, error) {
 
 	return splitAuth[1], nil
 }
+
+func GetAPIKey(headers http.Header) (string, error) {
+	authHeader := headers.Get("Authorization")
+	if authHeader == "" {
+		return "", ErrNoAuthorizationIncluded
+	}
+
+	splitAuth := strings.Split(authHeader, " ")
+	if len(splitAuth) < 2 || splitAuth[0] != "ApiKey" {
+		return "", errors.New("malformed authorization header")
+	}
+
+	return splitAuth[1], nil
+}
@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// SigningKey is one key a token can be signed or verified with. PrivateKey is
+// nil for keys that are only kept around to verify tokens during rotation.
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.PrivateKey
+	PublicKey  crypto.PublicKey
+}
+
+// KeyProvider hands out the key new tokens are signed with and every key that
+// tokens are still allowed to be verified with.
+type KeyProvider interface {
+	SigningKey() (*SigningKey, error)
+	VerificationKey(kid string) (*SigningKey, error)
+	PublicKeys() []*SigningKey
+}
+
+type hmacKeyProvider struct {
+	key *SigningKey
+}
+
+// NewHMACKeyProvider signs with HS256 using a shared secret. It publishes no
+// public keys.
+func NewHMACKeyProvider(secret string) KeyProvider {
+	return &hmacKeyProvider{
+		key: &SigningKey{
+			Method:     jwt.SigningMethodHS256,
+			PrivateKey: []byte(secret),
+			PublicKey:  []byte(secret),
+		},
+	}
+}
+
+func (p *hmacKeyProvider) SigningKey() (*SigningKey, error) {
+	return p.key, nil
+}
+
+func (p *hmacKeyProvider) VerificationKey(kid string) (*SigningKey, error) {
+	if kid != "" {
+		return nil, ErrUnknownKey
+	}
+	return p.key, nil
+}
+
+func (p *hmacKeyProvider) PublicKeys() []*SigningKey {
+	return nil
+}
+
+// KeySet signs with its current key and verifies with the current key and any
+// previous ones, so tokens minted before a rotation stay valid until expiry.
+type KeySet struct {
+	current *SigningKey
+	keys    map[string]*SigningKey
+	ordered []*SigningKey
+}
+
+func NewKeySet(current *SigningKey, previous ...*SigningKey) (*KeySet, error) {
+	if current == nil || current.PrivateKey == nil {
+		return nil, errors.New("current key must have a private key")
+	}
+
+	set := &KeySet{
+		current: current,
+		keys:    map[string]*SigningKey{},
+	}
+
+	for _, key := range append([]*SigningKey{current}, previous...) {
+		if _, ok := set.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id: %s", key.ID)
+		}
+		set.keys[key.ID] = key
+		set.ordered = append(set.ordered, key)
+	}
+
+	return set, nil
+}
+
+func (s *KeySet) SigningKey() (*SigningKey, error) {
+	return s.current, nil
+}
+
+func (s *KeySet) VerificationKey(kid string) (*SigningKey, error) {
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+func (s *KeySet) PublicKeys() []*SigningKey {
+	return s.ordered
+}
+
+// LoadKeyFromPEM reads an RSA or Ed25519 key from a PEM file. Private keys
+// (PKCS#8 or PKCS#1) can sign; public keys (PKIX) can only verify. When kid is
+// empty it is derived from the public key.
+func LoadKeyFromPEM(path, kid string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block found", path)
+	}
+
+	var privateKey crypto.PrivateKey
+	var publicKey crypto.PublicKey
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		publicKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block type %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	key, err := NewSigningKey(kid, privateKey, publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return key, nil
+}
+
+// NewSigningKey picks the signing method from the key type. Either key may be
+// nil; the public key is derived from the private one when possible.
+func NewSigningKey(kid string, privateKey crypto.PrivateKey, publicKey crypto.PublicKey) (*SigningKey, error) {
+	if privateKey != nil {
+		signer, ok := privateKey.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("private key cannot sign")
+		}
+		publicKey = signer.Public()
+	}
+
+	key := &SigningKey{
+		ID:         kid,
+		PrivateKey: privateKey,
+		PublicKey:  publicKey,
+	}
+
+	switch publicKey.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", publicKey)
+	}
+
+	if key.ID == "" {
+		der, err := x509.MarshalPKIXPublicKey(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(der)
+		key.ID = base64.RawURLEncoding.EncodeToString(sum[:12])
+	}
+
+	return key, nil
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of a provider in JSON Web Key Set format.
+func JWKS(provider KeyProvider) JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+
+	for _, key := range provider.PublicKeys() {
+		jwk := JWK{
+			Kid: key.ID,
+			Use: "sig",
+			Alg: key.Method.Alg(),
+		}
+
+		switch pub := key.PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
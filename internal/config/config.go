@@ -0,0 +1,345 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MinSecretLength is the shortest JWT_SECRET accepted. HS256 keys shorter than
+// the hash output weaken the signature.
+const MinSecretLength = 32
+
+// supportedAlgorithms are the JWT algorithms the keys chirpy can load sign
+// with.
+var supportedAlgorithms = []string{"HS256", "RS256", "EdDSA"}
+
+// Config holds everything the server needs to start. Values are applied in
+// order of increasing precedence: defaults, the YAML config file, environment
+// variables and finally command-line flags.
+type Config struct {
+	Port     string `yaml:"port"`
+	DBURL    string `yaml:"db_url"`
+	Platform string `yaml:"platform"`
+
+	JWTSecret         string        `yaml:"jwt_secret"`
+	JWTSigningKeyFile string        `yaml:"jwt_signing_key_file"`
+	JWTVerifyKeyFiles []string      `yaml:"jwt_verify_key_files"`
+	JWTAudience       string        `yaml:"jwt_audience"`
+	JWTLeeway         time.Duration `yaml:"jwt_leeway"`
+	JWTAlgorithms     []string      `yaml:"jwt_algorithms"`
+	AccessTokenTTL    time.Duration `yaml:"access_token_ttl"`
+	RefreshTokenTTL   time.Duration `yaml:"refresh_token_ttl"`
+
+	PolkaKey string `yaml:"polka_key"`
+	AdminKey string `yaml:"admin_key"`
+
+	AutoMigrate bool `yaml:"auto_migrate"`
+
+	ChirpMaxLength   int      `yaml:"chirp_max_length"`
+	ProfaneWords     []string `yaml:"profane_words"`
+	ProfaneWordsFile string   `yaml:"profane_words_file"`
+	ProfanityMode    string   `yaml:"profanity_mode"`
+
+	ProfanityReloadInterval time.Duration `yaml:"profanity_reload_interval"`
+
+	DeletedRetention time.Duration `yaml:"deleted_retention"`
+	PurgeInterval    time.Duration `yaml:"purge_interval"`
+
+	DBTimeout       time.Duration `yaml:"db_timeout"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	ShutdownDelay   time.Duration `yaml:"shutdown_delay"`
+}
+
+func Default() Config {
+	return Config{
+		Port:                    "8080",
+		Platform:                "prod",
+		AccessTokenTTL:          time.Hour,
+		RefreshTokenTTL:         60 * 24 * time.Hour,
+		ChirpMaxLength:          140,
+		ProfanityMode:           "mask",
+		ProfanityReloadInterval: time.Minute,
+		DeletedRetention:        30 * 24 * time.Hour,
+		PurgeInterval:           time.Hour,
+		DBTimeout:               5 * time.Second,
+		ReadTimeout:             10 * time.Second,
+		WriteTimeout:            15 * time.Second,
+		IdleTimeout:             60 * time.Second,
+		ShutdownTimeout:         15 * time.Second,
+		ShutdownDelay:           5 * time.Second,
+	}
+}
+
+// option ties a Config field to its environment variable and flag.
+type option struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+var options = []option{
+	{"PORT", "port", "port to listen on", setString(func(c *Config) *string { return &c.Port })},
+	{"DB_URL", "db-url", "postgres connection string", setString(func(c *Config) *string { return &c.DBURL })},
+	{"PLATFORM", "platform", "deployment platform, dev enables /admin/reset", setString(func(c *Config) *string { return &c.Platform })},
+	{"JWT_SECRET", "jwt-secret", "HS256 signing secret", setString(func(c *Config) *string { return &c.JWTSecret })},
+	{"JWT_SIGNING_KEY_FILE", "jwt-signing-key-file", "PEM private key to sign tokens with", setString(func(c *Config) *string { return &c.JWTSigningKeyFile })},
+	{"JWT_VERIFY_KEY_FILES", "jwt-verify-key-files", "comma separated PEM keys still accepted during rotation", setList(func(c *Config) *[]string { return &c.JWTVerifyKeyFiles })},
+	{"JWT_AUDIENCE", "jwt-audience", "audience put in and required from access tokens", setString(func(c *Config) *string { return &c.JWTAudience })},
+	{"JWT_LEEWAY", "jwt-leeway", "allowed clock skew when validating tokens", setDuration(func(c *Config) *time.Duration { return &c.JWTLeeway })},
+	{"JWT_ALGORITHMS", "jwt-algorithms", "comma separated signing algorithms accepted on tokens, empty accepts the loaded keys' own", setList(func(c *Config) *[]string { return &c.JWTAlgorithms })},
+	{"ACCESS_TOKEN_TTL", "access-token-ttl", "access token lifetime", setDuration(func(c *Config) *time.Duration { return &c.AccessTokenTTL })},
+	{"REFRESH_TOKEN_TTL", "refresh-token-ttl", "refresh token lifetime", setDuration(func(c *Config) *time.Duration { return &c.RefreshTokenTTL })},
+	{"POLKA_KEY", "polka-key", "API key for the Polka webhook", setString(func(c *Config) *string { return &c.PolkaKey })},
+	{"ADMIN_API_KEY", "admin-api-key", "API key for the /admin banned word and moderation endpoints, which are disabled without one", setString(func(c *Config) *string { return &c.AdminKey })},
+	{"AUTO_MIGRATE", "auto-migrate", "apply pending migrations on startup, true or false", setBool(func(c *Config) *bool { return &c.AutoMigrate })},
+	{"CHIRP_MAX_LENGTH", "chirp-max-length", "maximum chirp length in characters", setInt(func(c *Config) *int { return &c.ChirpMaxLength })},
+	{"PROFANE_WORDS", "profane-words", "comma separated words to filter on top of the banned_words table", setList(func(c *Config) *[]string { return &c.ProfaneWords })},
+	{"PROFANE_WORDS_FILE", "profane-words-file", "file with one word per line to filter on top of the banned_words table, replaces PROFANE_WORDS", setString(func(c *Config) *string { return &c.ProfaneWordsFile })},
+	{"PROFANITY_MODE", "profanity-mode", "what to do with profane chirps: mask, reject or flag", setString(func(c *Config) *string { return &c.ProfanityMode })},
+	{"PROFANITY_RELOAD_INTERVAL", "profanity-reload-interval", "how often to reread the profane word list, so banned word changes reach every replica", setDuration(func(c *Config) *time.Duration { return &c.ProfanityReloadInterval })},
+	{"DELETED_RETENTION", "deleted-retention", "how long deleted users and chirps can be restored before they are purged", setDuration(func(c *Config) *time.Duration { return &c.DeletedRetention })},
+	{"PURGE_INTERVAL", "purge-interval", "how often to purge deleted users and chirps past the retention window", setDuration(func(c *Config) *time.Duration { return &c.PurgeInterval })},
+	{"DB_TIMEOUT", "db-timeout", "deadline for the database work of one request", setDuration(func(c *Config) *time.Duration { return &c.DBTimeout })},
+	{"HTTP_READ_TIMEOUT", "read-timeout", "HTTP server read timeout", setDuration(func(c *Config) *time.Duration { return &c.ReadTimeout })},
+	{"HTTP_WRITE_TIMEOUT", "write-timeout", "HTTP server write timeout", setDuration(func(c *Config) *time.Duration { return &c.WriteTimeout })},
+	{"HTTP_IDLE_TIMEOUT", "idle-timeout", "HTTP server idle timeout", setDuration(func(c *Config) *time.Duration { return &c.IdleTimeout })},
+	{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "how long to drain connections on shutdown", setDuration(func(c *Config) *time.Duration { return &c.ShutdownTimeout })},
+	{"SHUTDOWN_DELAY", "shutdown-delay", "how long to keep serving with /api/healthz failing before draining, so load balancers notice", setDuration(func(c *Config) *time.Duration { return &c.ShutdownDelay })},
+}
+
+// Load builds the config from CHIRPY_CONFIG or -config, the environment and
+// args, which should not include the program name.
+func Load(args []string) (*Config, error) {
+	return load(args, os.Getenv)
+}
+
+// LoadMigrate is Load for the migrate subcommand, which only needs DB_URL.
+// The server-only settings are still read but not validated.
+func LoadMigrate(args []string) (*Config, error) {
+	return loadMigrate(args, os.Getenv)
+}
+
+func load(args []string, getenv func(string) string) (*Config, error) {
+	cfg, err := parse(args, getenv)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func loadMigrate(args []string, getenv func(string) string) (*Config, error) {
+	cfg, err := parse(args, getenv)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.DBURL == "" {
+		return nil, errors.New("DB_URL is required")
+	}
+
+	return cfg, nil
+}
+
+// parse applies the config file, environment and flags over the defaults.
+func parse(args []string, getenv func(string) string) (*Config, error) {
+	fs := flag.NewFlagSet("chirpy", flag.ContinueOnError)
+	configFile := fs.String("config", getenv("CHIRPY_CONFIG"), "path to a YAML config file")
+	flagValues := map[string]*string{}
+	for _, opt := range options {
+		flagValues[opt.flag] = fs.String(opt.flag, "", opt.usage+" (env "+opt.env+")")
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+
+	if *configFile != "" {
+		err = loadFile(&cfg, *configFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, opt := range options {
+		value := getenv(opt.env)
+		if value == "" {
+			continue
+		}
+		err = opt.set(&cfg, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", opt.env, err)
+		}
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+		for _, opt := range options {
+			if opt.flag == f.Name {
+				if setErr := opt.set(&cfg, *flagValues[f.Name]); setErr != nil {
+					err = fmt.Errorf("-%s: %w", f.Name, setErr)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	ext := filepath.Ext(path)
+	if ext != ".yaml" && ext != ".yml" {
+		return fmt.Errorf("%s: unsupported config file format %q", path, ext)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	err = yaml.Unmarshal(data, cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Validate reports every problem with the config at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.DBURL == "" {
+		errs = append(errs, errors.New("DB_URL is required"))
+	}
+
+	if c.JWTSigningKeyFile == "" {
+		if c.JWTSecret == "" {
+			errs = append(errs, errors.New("JWT_SECRET is required when JWT_SIGNING_KEY_FILE is not set"))
+		} else if len(c.JWTSecret) < MinSecretLength {
+			errs = append(errs, fmt.Errorf("JWT_SECRET must be at least %d characters", MinSecretLength))
+		}
+	}
+
+	for _, alg := range c.JWTAlgorithms {
+		if !slices.Contains(supportedAlgorithms, alg) {
+			errs = append(errs, fmt.Errorf("unsupported JWT algorithm: %q, expected one of %s", alg, strings.Join(supportedAlgorithms, ", ")))
+		}
+	}
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 0 || port > 65535 {
+		errs = append(errs, fmt.Errorf("invalid port: %q", c.Port))
+	}
+
+	if c.ChirpMaxLength < 1 {
+		errs = append(errs, errors.New("chirp max length must be positive"))
+	}
+
+	switch c.ProfanityMode {
+	case "mask", "reject", "flag":
+	default:
+		errs = append(errs, fmt.Errorf("invalid profanity mode: %q, expected mask, reject or flag", c.ProfanityMode))
+	}
+
+	if c.ProfanityReloadInterval <= 0 {
+		errs = append(errs, errors.New("PROFANITY_RELOAD_INTERVAL must be positive"))
+	}
+
+	if c.AccessTokenTTL <= 0 || c.RefreshTokenTTL <= 0 {
+		errs = append(errs, errors.New("token lifetimes must be positive"))
+	}
+
+	if c.DeletedRetention <= 0 || c.PurgeInterval <= 0 {
+		errs = append(errs, errors.New("DELETED_RETENTION and PURGE_INTERVAL must be positive"))
+	}
+
+	if c.DBTimeout <= 0 {
+		errs = append(errs, errors.New("DB_TIMEOUT must be positive"))
+	}
+
+	if c.ShutdownDelay < 0 {
+		errs = append(errs, errors.New("SHUTDOWN_DELAY must not be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func setString(field func(*Config) *string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func setList(field func(*Config) *[]string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			if item != "" {
+				list = append(list, item)
+			}
+		}
+		*field(c) = list
+		return nil
+	}
+}
+
+func setInt(field func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = i
+		return nil
+	}
+}
+
+func setBool(field func(*Config) *bool) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func setDuration(field func(*Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = d
+		return nil
+	}
+}
@@ -0,0 +1,237 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func TestLoadPrecedence(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "chirpy.yaml")
+	err := os.WriteFile(configFile, []byte(`
+port: "9000"
+db_url: postgres://file
+jwt_secret: `+testSecret+`
+chirp_max_length: 200
+access_token_ttl: 30m
+profane_words: [foo, bar]
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		args      []string
+		env       map[string]string
+		wantPort  string
+		wantDBURL string
+		wantMax   int
+		wantTTL   time.Duration
+		wantWords []string
+	}{
+		{
+			name:      "File overrides defaults",
+			args:      []string{"-config", configFile},
+			wantPort:  "9000",
+			wantDBURL: "postgres://file",
+			wantMax:   200,
+			wantTTL:   30 * time.Minute,
+			wantWords: []string{"foo", "bar"},
+		},
+		{
+			name: "Env overrides file",
+			args: []string{"-config", configFile},
+			env: map[string]string{
+				"PORT":          "9100",
+				"PROFANE_WORDS": "baz, qux",
+			},
+			wantPort:  "9100",
+			wantDBURL: "postgres://file",
+			wantMax:   200,
+			wantTTL:   30 * time.Minute,
+			wantWords: []string{"baz", "qux"},
+		},
+		{
+			name: "Flags override env",
+			args: []string{"-port", "9200", "-access-token-ttl", "5m"},
+			env: map[string]string{
+				"CHIRPY_CONFIG":    configFile,
+				"PORT":             "9100",
+				"ACCESS_TOKEN_TTL": "10m",
+			},
+			wantPort:  "9200",
+			wantDBURL: "postgres://file",
+			wantMax:   200,
+			wantTTL:   5 * time.Minute,
+			wantWords: []string{"foo", "bar"},
+		},
+		{
+			name: "Env only",
+			env: map[string]string{
+				"DB_URL":     "postgres://env",
+				"JWT_SECRET": testSecret,
+			},
+			wantPort:  "8080",
+			wantDBURL: "postgres://env",
+			wantMax:   140,
+			wantTTL:   time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := load(tt.args, func(key string) string { return tt.env[key] })
+			if err != nil {
+				t.Fatalf("load() error = %v", err)
+			}
+			if cfg.Port != tt.wantPort {
+				t.Errorf("Port = %q, want %q", cfg.Port, tt.wantPort)
+			}
+			if cfg.DBURL != tt.wantDBURL {
+				t.Errorf("DBURL = %q, want %q", cfg.DBURL, tt.wantDBURL)
+			}
+			if cfg.ChirpMaxLength != tt.wantMax {
+				t.Errorf("ChirpMaxLength = %d, want %d", cfg.ChirpMaxLength, tt.wantMax)
+			}
+			if cfg.AccessTokenTTL != tt.wantTTL {
+				t.Errorf("AccessTokenTTL = %v, want %v", cfg.AccessTokenTTL, tt.wantTTL)
+			}
+			if len(cfg.ProfaneWords) != len(tt.wantWords) {
+				t.Fatalf("ProfaneWords = %v, want %v", cfg.ProfaneWords, tt.wantWords)
+			}
+			for i := range tt.wantWords {
+				if cfg.ProfaneWords[i] != tt.wantWords[i] {
+					t.Errorf("ProfaneWords = %v, want %v", cfg.ProfaneWords, tt.wantWords)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{
+			name: "Missing DB URL",
+			env:  map[string]string{"JWT_SECRET": testSecret},
+		},
+		{
+			name: "Missing secret",
+			env:  map[string]string{"DB_URL": "postgres://env"},
+		},
+		{
+			name: "Weak secret",
+			env:  map[string]string{"DB_URL": "postgres://env", "JWT_SECRET": "secret"},
+		},
+		{
+			name: "Invalid port",
+			args: []string{"-port", "http"},
+			env:  map[string]string{"DB_URL": "postgres://env", "JWT_SECRET": testSecret},
+		},
+		{
+			name: "Invalid bool",
+			args: []string{"-auto-migrate", "sometimes"},
+			env:  map[string]string{"DB_URL": "postgres://env", "JWT_SECRET": testSecret},
+		},
+		{
+			name: "Invalid duration",
+			env:  map[string]string{"DB_URL": "postgres://env", "JWT_SECRET": testSecret, "DB_TIMEOUT": "soon"},
+		},
+		{
+			name: "Invalid profanity mode",
+			env:  map[string]string{"DB_URL": "postgres://env", "JWT_SECRET": testSecret, "PROFANITY_MODE": "delete"},
+		},
+		{
+			name: "Zero profanity reload interval",
+			env:  map[string]string{"DB_URL": "postgres://env", "JWT_SECRET": testSecret, "PROFANITY_RELOAD_INTERVAL": "0s"},
+		},
+		{
+			name: "Negative retention",
+			env:  map[string]string{"DB_URL": "postgres://env", "JWT_SECRET": testSecret, "DELETED_RETENTION": "-1h"},
+		},
+		{
+			name: "Unsupported JWT algorithm",
+			env:  map[string]string{"DB_URL": "postgres://env", "JWT_SECRET": testSecret, "JWT_ALGORITHMS": "HS256,none"},
+		},
+		{
+			name: "Negative shutdown delay",
+			env:  map[string]string{"DB_URL": "postgres://env", "JWT_SECRET": testSecret, "SHUTDOWN_DELAY": "-1s"},
+		},
+		{
+			name: "Unknown flag",
+			args: []string{"-verbose"},
+			env:  map[string]string{"DB_URL": "postgres://env", "JWT_SECRET": testSecret},
+		},
+		{
+			name: "Unsupported file format",
+			args: []string{"-config", "chirpy.json"},
+			env:  map[string]string{"DB_URL": "postgres://env", "JWT_SECRET": testSecret},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := load(tt.args, func(key string) string { return tt.env[key] })
+			if err == nil {
+				t.Error("load() expected error")
+			}
+		})
+	}
+}
+
+func TestSigningKeyFileReplacesSecret(t *testing.T) {
+	env := map[string]string{
+		"DB_URL":               "postgres://env",
+		"JWT_SIGNING_KEY_FILE": "/etc/chirpy/key.pem",
+	}
+	_, err := load(nil, func(key string) string { return env[key] })
+	if err != nil {
+		t.Errorf("load() error = %v", err)
+	}
+}
+
+func TestLoadMigrate(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+	}{
+		{
+			name: "Only DB URL",
+			env:  map[string]string{"DB_URL": "postgres://env"},
+		},
+		{
+			name: "Server settings are not validated",
+			env:  map[string]string{"DB_URL": "postgres://env", "JWT_SECRET": "secret", "PROFANITY_MODE": "delete"},
+		},
+		{
+			name:    "Missing DB URL",
+			env:     map[string]string{"JWT_SECRET": testSecret},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid duration",
+			env:     map[string]string{"DB_URL": "postgres://env", "DB_TIMEOUT": "soon"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadMigrate(nil, func(key string) string { return tt.env[key] })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadMigrate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && cfg.DBURL != tt.env["DB_URL"] {
+				t.Errorf("DBURL = %q, want %q", cfg.DBURL, tt.env["DB_URL"])
+			}
+		})
+	}
+}
@@ -7,28 +7,68 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
 
+const adjustChirpLikeCount = `-- name: AdjustChirpLikeCount :one
+UPDATE chirps
+SET like_count = like_count + $1
+WHERE id = $2
+RETURNING id, created_at, updated_at, body, user_id, flagged, edited_at, deleted_at, like_count
+`
+
+type AdjustChirpLikeCountParams struct {
+	Delta int32
+	ID    uuid.UUID
+}
+
+func (q *Queries) AdjustChirpLikeCount(ctx context.Context, arg AdjustChirpLikeCountParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, adjustChirpLikeCount, arg.Delta, arg.ID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Flagged,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.LikeCount,
+	)
+	return i, err
+}
+
+const countUserChirps = `-- name: CountUserChirps :one
+SELECT count(*) FROM chirps
+WHERE user_id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) CountUserChirps(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUserChirps, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (created_at, updated_at, body, user_id)
-VALUES (
-  NOW(),
-  NOW(),
-  $1,
-  $2
-)
-RETURNING id, created_at, updated_at, body, user_id
+INSERT INTO chirps (created_at, updated_at, body, user_id, flagged)
+SELECT NOW(), NOW(), $1, users.id, $3
+FROM users
+WHERE users.id = $2 AND users.deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, flagged, edited_at, deleted_at, like_count
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body    string
+	UserID  uuid.UUID
+	Flagged bool
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID, arg.Flagged)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -36,12 +76,17 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.Flagged,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.LikeCount,
 	)
 	return i, err
 }
 
 const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, flagged, edited_at, deleted_at, like_count FROM chirps
+WHERE deleted_at IS NULL
 ORDER BY created_at ASC
 `
 
@@ -60,6 +105,10 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.Flagged,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.LikeCount,
 		); err != nil {
 			return nil, err
 		}
@@ -74,9 +123,32 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 	return items, nil
 }
 
+const getChirpForUpdate = `-- name: GetChirpForUpdate :one
+SELECT id, created_at, updated_at, body, user_id, flagged, edited_at, deleted_at, like_count FROM chirps
+WHERE id = $1 AND deleted_at IS NULL
+FOR UPDATE
+`
+
+func (q *Queries) GetChirpForUpdate(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpForUpdate, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Flagged,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.LikeCount,
+	)
+	return i, err
+}
+
 const getChirpWithId = `-- name: GetChirpWithId :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps 
-WHERE id = $1
+SELECT id, created_at, updated_at, body, user_id, flagged, edited_at, deleted_at, like_count FROM chirps 
+WHERE id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) GetChirpWithId(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -88,6 +160,279 @@ func (q *Queries) GetChirpWithId(ctx context.Context, id uuid.UUID) (Chirp, erro
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.Flagged,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.LikeCount,
+	)
+	return i, err
+}
+
+const listChirpsAsc = `-- name: ListChirpsAsc :many
+SELECT id, created_at, updated_at, body, user_id, flagged, edited_at, deleted_at, like_count FROM chirps
+WHERE deleted_at IS NULL
+  AND ($1::uuid IS NULL OR user_id = $1)
+  AND (
+    $2::timestamp IS NULL
+    OR (created_at, id) > ($2, $3::uuid)
+  )
+ORDER BY created_at ASC, id ASC
+LIMIT $4
+`
+
+type ListChirpsAscParams struct {
+	UserID          uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+}
+
+func (q *Queries) ListChirpsAsc(ctx context.Context, arg ListChirpsAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsAsc,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Flagged,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.LikeCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsDesc = `-- name: ListChirpsDesc :many
+SELECT id, created_at, updated_at, body, user_id, flagged, edited_at, deleted_at, like_count FROM chirps
+WHERE deleted_at IS NULL
+  AND ($1::uuid IS NULL OR user_id = $1)
+  AND (
+    $2::timestamp IS NULL
+    OR (created_at, id) < ($2, $3::uuid)
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type ListChirpsDescParams struct {
+	UserID          uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+}
+
+func (q *Queries) ListChirpsDesc(ctx context.Context, arg ListChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDesc,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Flagged,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.LikeCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserChirps = `-- name: ListUserChirps :many
+SELECT id, created_at, updated_at, body, user_id, flagged, edited_at, deleted_at, like_count FROM chirps
+WHERE user_id = $1 AND deleted_at IS NULL
+ORDER BY created_at ASC, id ASC
+`
+
+func (q *Queries) ListUserChirps(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listUserChirps, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Flagged,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.LikeCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeDeletedChirps = `-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at < NOW() - make_interval(secs => $1::float8)
+`
+
+func (q *Queries) PurgeDeletedChirps(ctx context.Context, retentionSeconds float64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedChirps, retentionSeconds)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreChirp = `-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL
+WHERE id = $1
+  AND deleted_at > NOW() - make_interval(secs => $2::float8)
+  AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+RETURNING id, created_at, updated_at, body, user_id, flagged, edited_at, deleted_at, like_count
+`
+
+type RestoreChirpParams struct {
+	ID               uuid.UUID
+	RetentionSeconds float64
+}
+
+func (q *Queries) RestoreChirp(ctx context.Context, arg RestoreChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirp, arg.ID, arg.RetentionSeconds)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Flagged,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.LikeCount,
+	)
+	return i, err
+}
+
+const restoreUserChirps = `-- name: RestoreUserChirps :exec
+UPDATE chirps
+SET deleted_at = NULL
+FROM users
+WHERE chirps.user_id = users.id
+  AND users.id = $1
+  AND chirps.deleted_at = users.deleted_at
+`
+
+func (q *Queries) RestoreUserChirps(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, restoreUserChirps, id)
+	return err
+}
+
+const softDeleteChirp = `-- name: SoftDeleteChirp :execrows
+UPDATE chirps
+SET deleted_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) SoftDeleteChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, softDeleteChirp, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const softDeleteUserChirps = `-- name: SoftDeleteUserChirps :exec
+UPDATE chirps
+SET deleted_at = users.deleted_at
+FROM users
+WHERE chirps.user_id = users.id
+  AND users.id = $1
+  AND chirps.deleted_at IS NULL
+`
+
+func (q *Queries) SoftDeleteUserChirps(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, softDeleteUserChirps, id)
+	return err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, flagged = $3, updated_at = NOW(), edited_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, flagged, edited_at, deleted_at, like_count
+`
+
+type UpdateChirpBodyParams struct {
+	ID      uuid.UUID
+	Body    string
+	Flagged bool
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.ID, arg.Body, arg.Flagged)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Flagged,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.LikeCount,
 	)
 	return i, err
 }
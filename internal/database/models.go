@@ -11,21 +11,58 @@ import (
 	"github.com/google/uuid"
 )
 
+type BannedWord struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Word      string
+}
+
 type Chirp struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	Body      string
 	UserID    uuid.UUID
+	Flagged   bool
+	EditedAt  sql.NullTime
+	DeletedAt sql.NullTime
+	LikeCount int32
 }
 
-type RefreshToken struct {
-	Token     string
+type ChirpLike struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
+}
+
+type ChirpMention struct {
+	ChirpID   uuid.UUID
 	UserID    uuid.UUID
-	ExpiresAt time.Time
-	RevokedAt sql.NullTime
+	CreatedAt time.Time
+}
+
+type ChirpRevision struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	ChirpID   uuid.UUID
+	Body      string
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type RefreshToken struct {
+	Token      string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	ExpiresAt  time.Time
+	RevokedAt  sql.NullTime
+	FamilyID   uuid.UUID
+	ReplacedBy sql.NullString
 }
 
 type User struct {
@@ -34,4 +71,10 @@ type User struct {
 	UpdatedAt      time.Time
 	Email          string
 	HashedPassowrd string
+	IsChirpyRed    bool
+	DeletedAt      sql.NullTime
+	Handle         sql.NullString
+	DisplayName    sql.NullString
+	Bio            sql.NullString
+	AvatarUrl      sql.NullString
 }
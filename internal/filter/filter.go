@@ -0,0 +1,320 @@
+package filter
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Mode is what a deployment does with text that contains a banned word.
+type Mode string
+
+const (
+	// ModeMask replaces every banned word with asterisks.
+	ModeMask Mode = "mask"
+	// ModeReject refuses the text.
+	ModeReject Mode = "reject"
+	// ModeFlag accepts the text unchanged and marks it for review.
+	ModeFlag Mode = "flag"
+)
+
+func ParseMode(s string) (Mode, error) {
+	switch mode := Mode(s); mode {
+	case ModeMask, ModeReject, ModeFlag:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown filter mode %q, expected mask, reject or flag", s)
+	}
+}
+
+// Source supplies the banned word list. It is read on every Reload.
+type Source interface {
+	Words(ctx context.Context) ([]string, error)
+}
+
+// Static is a fixed word list.
+type Static []string
+
+func (s Static) Words(ctx context.Context) ([]string, error) {
+	return s, nil
+}
+
+// File reads one word per line from a file. Blank lines and lines starting
+// with # are ignored.
+type File string
+
+func (f File) Words(ctx context.Context) ([]string, error) {
+	file, err := os.Open(string(f))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", f, err)
+	}
+
+	return words, nil
+}
+
+// SourceFunc adapts a function to a Source.
+type SourceFunc func(ctx context.Context) ([]string, error)
+
+func (f SourceFunc) Words(ctx context.Context) ([]string, error) {
+	return f(ctx)
+}
+
+// Sources combines the word lists of several sources. Reloading fails if any
+// of them fails.
+type Sources []Source
+
+func (s Sources) Words(ctx context.Context) ([]string, error) {
+	var words []string
+	for _, source := range s {
+		more, err := source.Words(ctx)
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, more...)
+	}
+	return words, nil
+}
+
+// Match is one banned word found in a text. Start and End are byte offsets
+// of the word as written.
+type Match struct {
+	Word  string
+	Text  string
+	Start int
+	End   int
+}
+
+type Result struct {
+	// Masked is the input with every match replaced by one asterisk per
+	// character.
+	Masked  string
+	Matches []Match
+}
+
+func (r Result) Clean() bool {
+	return len(r.Matches) == 0
+}
+
+// Words returns the matched words as written, without duplicates.
+func (r Result) Words() []string {
+	seen := map[string]bool{}
+	var words []string
+	for _, m := range r.Matches {
+		if !seen[m.Text] {
+			seen[m.Text] = true
+			words = append(words, m.Text)
+		}
+	}
+	return words
+}
+
+// Filter finds banned words in text. Words only match whole words, after
+// Unicode normalization, case folding and undoing common leetspeak, so
+// "K3rfuffle!" matches "kerfuffle" but "kerfuffles" does not. A Filter is
+// safe for concurrent use and its list can be reloaded while in use.
+type Filter struct {
+	source Source
+
+	mu    sync.RWMutex
+	words map[string]string
+}
+
+// New returns a filter with an empty list; call Reload to read source.
+func New(source Source) *Filter {
+	return &Filter{source: source, words: map[string]string{}}
+}
+
+// Reload replaces the word list with the current contents of the source. The
+// old list stays in use if the source fails.
+func (f *Filter) Reload(ctx context.Context) error {
+	words, err := f.source.Words(ctx)
+	if err != nil {
+		return err
+	}
+	f.SetWords(words)
+	return nil
+}
+
+func (f *Filter) SetWords(words []string) {
+	normalized := make(map[string]string, len(words))
+	for _, word := range words {
+		key := normalize(strings.TrimSpace(word))
+		if key != "" {
+			normalized[key] = word
+		}
+	}
+
+	f.mu.Lock()
+	f.words = normalized
+	f.mu.Unlock()
+}
+
+func (f *Filter) Len() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.words)
+}
+
+func (f *Filter) Check(text string) Result {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var b strings.Builder
+	var matches []Match
+	last := 0
+
+	for _, tok := range tokenize(text) {
+		for _, m := range f.match(text[tok.start:tok.end]) {
+			start := tok.start + m.start
+			end := tok.start + m.end
+
+			matches = append(matches, Match{Word: m.word, Text: text[start:end], Start: start, End: end})
+			b.WriteString(text[last:start])
+			b.WriteString(mask(text[start:end]))
+			last = end
+		}
+	}
+
+	if len(matches) == 0 {
+		return Result{Masked: text}
+	}
+	b.WriteString(text[last:])
+
+	return Result{Masked: b.String(), Matches: matches}
+}
+
+type hit struct {
+	span
+	word string
+}
+
+// match finds the banned words in a token. Leetspeak symbols can stand for a
+// letter, as in "$h4rb3rt", or sit between words, as in "fornax+kerfuffle" or
+// a trailing "!", so the token is cut at every symbol and, from the left, the
+// longest run of pieces that is a banned word wins. A token without symbols
+// is only ever looked up whole. It returns byte ranges within the token.
+func (f *Filter) match(token string) []hit {
+	cuts := []int{0}
+	for i, r := range token {
+		if !isLeetSymbol(r) {
+			continue
+		}
+		if cuts[len(cuts)-1] != i {
+			cuts = append(cuts, i)
+		}
+		cuts = append(cuts, i+utf8.RuneLen(r))
+	}
+	if cuts[len(cuts)-1] != len(token) {
+		cuts = append(cuts, len(token))
+	}
+
+	var hits []hit
+	for a := 0; a < len(cuts)-1; a++ {
+		for b := len(cuts) - 1; b > a; b-- {
+			part := token[cuts[a]:cuts[b]]
+			if strings.TrimFunc(part, isLeetSymbol) == "" {
+				continue
+			}
+			if word, ok := f.words[normalize(part)]; ok {
+				hits = append(hits, hit{span{cuts[a], cuts[b]}, word})
+				a = b - 1
+				break
+			}
+		}
+	}
+	return hits
+}
+
+// mask returns one asterisk per character of word. Combining marks belong to
+// the character before them, so a decomposed "ä" is still one asterisk.
+func mask(word string) string {
+	n := 0
+	for _, r := range word {
+		if !unicode.Is(unicode.Mn, r) {
+			n++
+		}
+	}
+	return strings.Repeat("*", n)
+}
+
+type span struct {
+	start int
+	end   int
+}
+
+// tokenize splits text into runs of letters, digits, combining marks and
+// leetspeak symbols.
+func tokenize(text string) []span {
+	var spans []span
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r) || isLeetSymbol(r)
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			spans = append(spans, span{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, span{start, len(text)})
+	}
+	return spans
+}
+
+var leet = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+	'@': 'a',
+	'$': 's',
+	'!': 'i',
+	'|': 'i',
+	'+': 't',
+}
+
+func isLeetSymbol(r rune) bool {
+	_, ok := leet[r]
+	return ok && !unicode.IsNumber(r)
+}
+
+// normalize brings a word to the form it is compared in: NFKC, so fullwidth
+// letters and ligatures compare like the letters they stand for, case folded
+// and with leetspeak substitutions undone. Only the key changes; masking uses
+// the word's bytes as written.
+func normalize(word string) string {
+	// Casers are stateful, so each call needs its own.
+	folded := cases.Fold().String(norm.NFKC.String(word))
+	return strings.Map(func(r rune) rune {
+		if plain, ok := leet[r]; ok {
+			return plain
+		}
+		return r
+	}, folded)
+}
@@ -0,0 +1,121 @@
+package filter
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	f := New(Static{"kerfuffle", "sharbert", "fornax", "\u00c4rger"})
+	if err := f.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		text       string
+		wantMasked string
+		wantWords  []string
+	}{
+		{name: "Clean", text: "This is a clean chirp", wantMasked: "This is a clean chirp"},
+		{name: "Whole word", text: "What a kerfuffle today", wantMasked: "What a ********* today", wantWords: []string{"kerfuffle"}},
+		{name: "Punctuation", text: "kerfuffle! Sharbert, fornax.", wantMasked: "*********! ********, ******.", wantWords: []string{"kerfuffle", "Sharbert", "fornax"}},
+		{name: "Case folding", text: "KERFUFFLE", wantMasked: "*********", wantWords: []string{"KERFUFFLE"}},
+		{name: "Leetspeak", text: "k3rfuffl3 $h4rb3rt f0rn@x", wantMasked: "********* ******** ******", wantWords: []string{"k3rfuffl3", "$h4rb3rt", "f0rn@x"}},
+		{name: "Joined by leetspeak symbols", text: "fornax+kerfuffle $h4rb3rt|f0rn@x!", wantMasked: "******+********* ********|******!", wantWords: []string{"fornax", "kerfuffle", "$h4rb3rt", "f0rn@x"}},
+		{name: "Substring is not a match", text: "kerfuffles sharberts unfornaxed", wantMasked: "kerfuffles sharberts unfornaxed"},
+		{name: "Unicode normalization", text: "so much \u00e4rger", wantMasked: "so much *****", wantWords: []string{"\u00e4rger"}},
+		{name: "Decomposed input", text: "so much a\u0308rger", wantMasked: "so much *****", wantWords: []string{"a\u0308rger"}},
+		{name: "Fullwidth", text: "what a ＫＥＲＦＵＦＦＬＥ", wantMasked: "what a *********", wantWords: []string{"ＫＥＲＦＵＦＦＬＥ"}},
+		{name: "Ligature", text: "what a kerfuﬀle", wantMasked: "what a ********", wantWords: []string{"kerfuﬀle"}},
+		{name: "Non-Latin text around a match", text: "日本語 fornax 🎉", wantMasked: "日本語 ****** 🎉", wantWords: []string{"fornax"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := f.Check(tt.text)
+			if res.Masked != tt.wantMasked {
+				t.Errorf("Check().Masked = %q, want %q", res.Masked, tt.wantMasked)
+			}
+			if !reflect.DeepEqual(res.Words(), tt.wantWords) {
+				t.Errorf("Check().Words() = %q, want %q", res.Words(), tt.wantWords)
+			}
+			if res.Clean() != (len(tt.wantWords) == 0) {
+				t.Errorf("Check().Clean() = %v", res.Clean())
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.txt")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f := New(File(path))
+
+	write("# banned words\nkerfuffle\n\n")
+	if err := f.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if f.Check("kerfuffle").Clean() {
+		t.Error("kerfuffle not matched after first load")
+	}
+
+	write("sharbert\n")
+	if err := f.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if !f.Check("kerfuffle").Clean() || f.Check("sharbert").Clean() {
+		t.Error("word list not replaced on reload")
+	}
+
+	os.Remove(path)
+	if err := f.Reload(context.Background()); err == nil {
+		t.Error("Reload() of a missing file succeeded")
+	}
+	if f.Check("sharbert").Clean() {
+		t.Error("failed reload dropped the previous list")
+	}
+}
+
+func TestSources(t *testing.T) {
+	calls := 0
+	db := SourceFunc(func(ctx context.Context) ([]string, error) {
+		calls++
+		return []string{"sharbert"}, nil
+	})
+
+	f := New(Sources{Static{"kerfuffle"}, db})
+	if err := f.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if calls != 1 || f.Len() != 2 {
+		t.Errorf("Reload() read %d words in %d calls, want 2 in 1", f.Len(), calls)
+	}
+
+	failing := SourceFunc(func(ctx context.Context) ([]string, error) {
+		return nil, errors.New("connection refused")
+	})
+	if _, err := (Sources{Static{"kerfuffle"}, failing}).Words(context.Background()); err == nil {
+		t.Error("Sources.Words() with a failing source succeeded")
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for _, s := range []string{"mask", "reject", "flag"} {
+		if mode, err := ParseMode(s); err != nil || string(mode) != s {
+			t.Errorf("ParseMode(%q) = %q, %v", s, mode, err)
+		}
+	}
+	if _, err := ParseMode("delete"); err == nil {
+		t.Error("ParseMode(\"delete\") succeeded")
+	}
+}
@@ -0,0 +1,5 @@
+module github.com/SzymonJaroslawski/chirpy/internal/filter
+
+go 1.23.4
+
+require golang.org/x/text v0.25.0
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/SzymonJaroslawski/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// setChirpLike likes or unlikes a chirp for userID and returns the chirp with
+// its updated count. Repeating either is a no-op. The like and the counter
+// change in one transaction so like_count always matches chirp_likes. It
+// returns sql.ErrNoRows when the chirp doesn't exist.
+func (cfg *apiConfig) setChirpLike(ctx context.Context, chirpID, userID uuid.UUID, liked bool) (database.Chirp, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Chirp{}, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
+
+	chirp, err := qtx.GetChirpWithId(ctx, chirpID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	var changed int64
+	var delta int32
+	if liked {
+		changed, err = qtx.CreateChirpLike(ctx, database.CreateChirpLikeParams{
+			ChirpID: chirp.ID,
+			UserID:  userID,
+		})
+		delta = 1
+	} else {
+		changed, err = qtx.DeleteChirpLike(ctx, database.DeleteChirpLikeParams{
+			ChirpID: chirp.ID,
+			UserID:  userID,
+		})
+		delta = -1
+	}
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if changed == 0 {
+		return chirp, nil
+	}
+
+	chirp, err = qtx.AdjustChirpLikeCount(ctx, database.AdjustChirpLikeCountParams{
+		Delta: delta,
+		ID:    chirp.ID,
+	})
+	if err != nil {
+		return database.Chirp{}, fmt.Errorf("updating like count: %w", err)
+	}
+
+	return chirp, tx.Commit()
+}
+
+// markLiked sets LikedByMe on the chirps userID has liked, with one query for
+// the whole page. Anonymous callers have liked nothing.
+func markLiked(ctx context.Context, q *database.Queries, userID uuid.UUID, chirps []Chirp) error {
+	if userID == uuid.Nil || len(chirps) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(chirps))
+	for i, chirp := range chirps {
+		ids[i] = chirp.Id
+	}
+
+	liked, err := q.ListLikedChirpIDs(ctx, database.ListLikedChirpIDsParams{
+		UserID:   userID,
+		ChirpIds: ids,
+	})
+	if err != nil {
+		return err
+	}
+
+	likedSet := make(map[uuid.UUID]bool, len(liked))
+	for _, id := range liked {
+		likedSet[id] = true
+	}
+	for i := range chirps {
+		chirps[i].LikedByMe = likedSet[chirps[i].Id]
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+const REQUEST_ID_HEADER = "X-Request-ID"
+
+const requestInfoContextKey contextKey = "requestInfo"
+
+// requestInfo is shared by the middlewares of one request so the access log
+// line can include what was learned further down the chain.
+type requestInfo struct {
+	id     string
+	userID uuid.UUID
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	info, ok := ctx.Value(requestInfoContextKey).(*requestInfo)
+	if !ok {
+		return ""
+	}
+	return info.id
+}
+
+// validRequestID accepts client supplied IDs of up to 128 printable ASCII
+// characters.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// contextHandler adds the request ID from the context to every record, so
+// handlers only need to use the slog *Context functions.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := requestIDFromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+func newLogger(w io.Writer) *slog.Logger {
+	return slog.New(contextHandler{slog.NewJSONHandler(w, nil)})
+}
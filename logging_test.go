@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SzymonJaroslawski/chirpy/internal/database"
+)
+
+func TestMiddlewareLog(t *testing.T) {
+	var buf bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(newLogger(&buf))
+	defer slog.SetDefault(defaultLogger)
+
+	cfg := newTestAPIConfig()
+	cfg.accessTokenTTL = time.Hour
+	userID := newFakeDB(t, cfg).addUser(database.User{}).ID
+	token, _ := cfg.makeAccessToken(userID)
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/things/{id}", cfg.middlewareAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slog.InfoContext(r.Context(), "handling thing")
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	})))
+	handler := middlewareLog(mux)
+
+	tests := []struct {
+		name          string
+		requestID     string
+		wantRequestID string
+	}{
+		{name: "Client request ID", requestID: "abc-123", wantRequestID: "abc-123"},
+		{name: "Generated request ID", requestID: ""},
+		{name: "Invalid request ID replaced", requestID: "has spaces\nand newlines"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			req := httptest.NewRequest(http.MethodGet, "/api/things/42", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			if tt.requestID != "" {
+				req.Header.Set(REQUEST_ID_HEADER, tt.requestID)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			gotRequestID := rec.Header().Get(REQUEST_ID_HEADER)
+			if tt.wantRequestID != "" && gotRequestID != tt.wantRequestID {
+				t.Errorf("%s = %q, want %q", REQUEST_ID_HEADER, gotRequestID, tt.wantRequestID)
+			}
+			if !validRequestID(gotRequestID) {
+				t.Errorf("%s = %q is not a valid request ID", REQUEST_ID_HEADER, gotRequestID)
+			}
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if len(lines) != 2 {
+				t.Fatalf("got %d log lines, want 2: %s", len(lines), buf.String())
+			}
+
+			var handlerLine, accessLine map[string]any
+			json.Unmarshal([]byte(lines[0]), &handlerLine)
+			json.Unmarshal([]byte(lines[1]), &accessLine)
+
+			if handlerLine["request_id"] != gotRequestID {
+				t.Errorf("handler log request_id = %v, want %q", handlerLine["request_id"], gotRequestID)
+			}
+
+			want := map[string]any{
+				"msg":        "request",
+				"method":     "GET",
+				"route":      "GET /api/things/{id}",
+				"status":     float64(http.StatusTeapot),
+				"bytes":      float64(len("short and stout")),
+				"remote_ip":  "192.0.2.1",
+				"user_id":    userID.String(),
+				"request_id": gotRequestID,
+			}
+			for key, value := range want {
+				if accessLine[key] != value {
+					t.Errorf("access log %s = %v, want %v", key, accessLine[key], value)
+				}
+			}
+		})
+	}
+}
@@ -1,47 +1,260 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
 	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/SzymonJaroslawski/chirpy/internal/auth"
+	"github.com/SzymonJaroslawski/chirpy/internal/config"
 	"github.com/SzymonJaroslawski/chirpy/internal/database"
+	"github.com/SzymonJaroslawski/chirpy/internal/filter"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type apiConfig struct {
-	db             *database.Queries
-	platform       string
-	secret         string
-	fileserverHits atomic.Int32
+	db               *database.Queries
+	dbConn           *sql.DB
+	platform         string
+	keys             auth.KeyProvider
+	jwtAudience      string
+	jwtLeeway        time.Duration
+	jwtAlgorithms    []string
+	accessTokenTTL   time.Duration
+	refreshTokenTTL  time.Duration
+	dbTimeout        time.Duration
+	deletedRetention time.Duration
+	polkaKey         string
+	adminKey         string
+	chirpMaxLength   int
+	profanity        *filter.Filter
+	profanityMode    filter.Mode
+	metrics          *metrics
+	fileserverHits   atomic.Int64
+	draining         atomic.Bool
 }
 
 func main() {
+	slog.SetDefault(newLogger(os.Stdout))
 	godotenv.Load(".env")
-	dbURL := os.Getenv("DB_URL")
-	secret := os.Getenv("JWT_SECRET")
-	db, err := sql.Open("postgres", dbURL)
+
+	args := os.Args[1:]
+	migrateCommand := ""
+	if len(args) > 0 && args[0] == "migrate" {
+		if len(args) < 2 {
+			log.Printf("Usage: chirpy migrate up|down|status [flags]")
+			os.Exit(2)
+		}
+		migrateCommand = args[1]
+		args = args[2:]
+	}
+
+	load := config.Load
+	if migrateCommand != "" {
+		load = config.LoadMigrate
+	}
+	conf, err := load(args)
+	if err != nil {
+		log.Printf("Error loading config: %s", err)
+		os.Exit(1)
+	}
+
+	db, err := sql.Open("postgres", conf.DBURL)
 	if err != nil {
 		log.Printf("Error connecting to database: %s", err)
 		os.Exit(1)
 	}
-	dbQueries := database.New(db)
-	platform := os.Getenv("PLATFORM")
-	config := &apiConfig{
-		fileserverHits: atomic.Int32{},
-		db:             dbQueries,
-		platform:       platform,
-		secret:         secret,
+
+	if migrateCommand != "" {
+		err = runMigrations(context.Background(), db, migrateCommand)
+		db.Close()
+		if err != nil {
+			log.Printf("Error running migrations: %s", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if conf.AutoMigrate {
+		err = runMigrations(context.Background(), db, "up")
+		if err != nil {
+			log.Printf("Error running migrations: %s", err)
+			os.Exit(1)
+		}
+	}
+
+	cfg, err := newAPIConfig(conf, db)
+	if err != nil {
+		log.Printf("Error configuring server: %s", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go reloadProfanity(ctx, cfg, conf.ProfanityReloadInterval)
+	go runPurger(ctx, cfg, conf.PurgeInterval)
+
+	ln, err := net.Listen("tcp", ":"+conf.Port)
+	if err != nil {
+		log.Printf("Error listening on port %s: %s", conf.Port, err)
+		os.Exit(1)
+	}
+
+	log.Printf("Server runing on: http://localhost:%s\n", conf.Port)
+	err = serve(ctx, cfg, ln, serverTimeouts{
+		read:  conf.ReadTimeout,
+		write: conf.WriteTimeout,
+		idle:  conf.IdleTimeout,
+		drain: conf.ShutdownTimeout,
+		ready: conf.ShutdownDelay,
+	})
+	if err != nil {
+		log.Printf("Error serving: %s", err)
+		os.Exit(1)
+	}
+}
+
+func newAPIConfig(conf *config.Config, db *sql.DB) (*apiConfig, error) {
+	keys, err := loadKeys(conf)
+	if err != nil {
+		return nil, fmt.Errorf("loading signing keys: %w", err)
+	}
+
+	// Tokens we sign ourselves have to pass validation.
+	signingKey, err := keys.SigningKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(conf.JWTAlgorithms) > 0 && !slices.Contains(conf.JWTAlgorithms, signingKey.Method.Alg()) {
+		return nil, fmt.Errorf("JWT_ALGORITHMS does not allow %s, the algorithm of the signing key", signingKey.Method.Alg())
+	}
+
+	profanityMode, err := filter.ParseMode(conf.ProfanityMode)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &apiConfig{
+		dbConn:           db,
+		platform:         conf.Platform,
+		keys:             keys,
+		jwtAudience:      conf.JWTAudience,
+		jwtLeeway:        conf.JWTLeeway,
+		jwtAlgorithms:    conf.JWTAlgorithms,
+		accessTokenTTL:   conf.AccessTokenTTL,
+		refreshTokenTTL:  conf.RefreshTokenTTL,
+		dbTimeout:        conf.DBTimeout,
+		deletedRetention: conf.DeletedRetention,
+		polkaKey:         conf.PolkaKey,
+		adminKey:         conf.AdminKey,
+		chirpMaxLength:   conf.ChirpMaxLength,
+		profanityMode:    profanityMode,
+	}
+	cfg.metrics = newMetrics(cfg, db)
+	cfg.db = database.New(instrumentedDB{db: db, metrics: cfg.metrics})
+
+	// Words from the config are always filtered; the banned_words table holds
+	// the ones managed through /admin/banned-words.
+	var configured filter.Source = filter.Static(conf.ProfaneWords)
+	if conf.ProfaneWordsFile != "" {
+		configured = filter.File(conf.ProfaneWordsFile)
 	}
-	serve(config)
+	cfg.profanity = filter.New(filter.Sources{configured, filter.SourceFunc(cfg.bannedWords)})
+
+	ctx, cancel := context.WithTimeout(context.Background(), conf.DBTimeout)
+	defer cancel()
+	err = cfg.profanity.Reload(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading profane words: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// loadKeys signs with the PEM key in JWTSigningKeyFile when it is set and
+// falls back to HS256 with JWTSecret otherwise. JWTVerifyKeyFiles are older
+// keys that are still accepted during rotation. Key IDs are derived from the
+// public keys so they survive being moved from one setting to the other.
+func loadKeys(conf *config.Config) (auth.KeyProvider, error) {
+	if conf.JWTSigningKeyFile == "" {
+		return auth.NewHMACKeyProvider(conf.JWTSecret), nil
+	}
+
+	current, err := auth.LoadKeyFromPEM(conf.JWTSigningKeyFile, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var previous []*auth.SigningKey
+	for _, path := range conf.JWTVerifyKeyFiles {
+		key, err := auth.LoadKeyFromPEM(path, "")
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, key)
+	}
+
+	return auth.NewKeySet(current, previous...)
 }
 
-func serve(cfg *apiConfig) {
-	const PORT = "8080"
+// reloadProfanity rereads the profane word list every interval and every time
+// the process gets SIGHUP, until ctx is canceled. The interval is what makes
+// /admin/banned-words changes reach the replicas that did not handle them.
+func reloadProfanity(ctx context.Context, cfg *apiConfig, interval time.Duration) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
 
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+		case <-ticker.C:
+		}
+
+		reloadCtx, cancel := context.WithTimeout(ctx, cfg.dbTimeout)
+		err := cfg.profanity.Reload(reloadCtx)
+		cancel()
+		if err != nil {
+			slog.Error("Error reloading profane words", "error", err)
+			continue
+		}
+		slog.Debug("Reloaded profane words", "count", cfg.profanity.Len())
+	}
+}
+
+type serverTimeouts struct {
+	read  time.Duration
+	write time.Duration
+	idle  time.Duration
+	drain time.Duration
+	// ready is how long to keep serving after /api/healthz starts failing,
+	// before the listener is closed.
+	ready time.Duration
+}
+
+// serve handles requests on ln until ctx is canceled. It then reports not
+// ready on /api/healthz for timeouts.ready while still serving, so load
+// balancers stop routing to it, waits up to timeouts.drain for in-flight
+// requests to finish and closes the database pool.
+func serve(ctx context.Context, cfg *apiConfig, ln net.Listener, timeouts serverTimeouts) error {
 	mux := http.NewServeMux()
 
 	staticDir := http.Dir("./static/")
@@ -49,33 +262,71 @@ func serve(cfg *apiConfig) {
 
 	mux.Handle("/app/", fileserverHanlder)
 
-	mux.HandleFunc("GET /api/healthz", handleHealthz)
+	mux.HandleFunc("GET /api/healthz", func(w http.ResponseWriter, r *http.Request) {
+		handleHealthz(w, r, cfg)
+	})
+
+	mux.HandleFunc("GET /.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		handleJWKS(w, r, cfg)
+	})
 
 	mux.HandleFunc("GET /admin/metrics", func(w http.ResponseWriter, r *http.Request) {
 		handleMetrics(w, r, cfg)
 	})
 
+	mux.Handle("GET /metrics", promhttp.HandlerFor(cfg.metrics.registry, promhttp.HandlerOpts{}))
+
 	mux.HandleFunc("POST /admin/reset", func(w http.ResponseWriter, r *http.Request) {
 		handleReset(w, r, cfg)
 	})
 
-	mux.HandleFunc("POST /api/validate_chirp", handleValidateChirp)
+	mux.Handle("GET /admin/banned-words", cfg.middlewareAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleListBannedWords(w, r, cfg)
+	})))
+
+	mux.Handle("POST /admin/banned-words", cfg.middlewareAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleCreateBannedWord(w, r, cfg)
+	})))
+
+	mux.Handle("DELETE /admin/banned-words/{word}", cfg.middlewareAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDeleteBannedWord(w, r, cfg)
+	})))
+
+	mux.Handle("DELETE /admin/users/{userID}", cfg.middlewareAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminDeleteUser(w, r, cfg)
+	})))
+
+	mux.Handle("POST /admin/users/{userID}/restore", cfg.middlewareAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminRestoreUser(w, r, cfg)
+	})))
+
+	mux.Handle("DELETE /admin/chirps/{chirpID}", cfg.middlewareAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminDeleteChirp(w, r, cfg)
+	})))
+
+	mux.Handle("POST /admin/chirps/{chirpID}/restore", cfg.middlewareAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminRestoreChirp(w, r, cfg)
+	})))
+
+	mux.HandleFunc("POST /api/validate_chirp", func(w http.ResponseWriter, r *http.Request) {
+		handleValidateChirp(w, r, cfg)
+	})
 
 	mux.HandleFunc("POST /api/users", func(w http.ResponseWriter, r *http.Request) {
 		handleCreateUser(w, r, cfg)
 	})
 
-	mux.HandleFunc("POST /api/chirps", func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("POST /api/chirps", cfg.middlewareAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		handleCreateChirp(w, r, cfg)
-	})
+	})))
 
-	mux.HandleFunc("GET /api/chirps", func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("GET /api/chirps", cfg.middlewareOptionalAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		handleGetAllChirps(w, r, cfg)
-	})
+	})))
 
-	mux.HandleFunc("GET /api/chirps/{chirpID}", func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("GET /api/chirps/{chirpID}", cfg.middlewareOptionalAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		handleGetChirp(w, r, cfg)
-	})
+	})))
 
 	mux.HandleFunc("POST /api/login", func(w http.ResponseWriter, r *http.Request) {
 		handleLogin(w, r, cfg)
@@ -89,18 +340,121 @@ func serve(cfg *apiConfig) {
 		handleRevoke(w, r, cfg)
 	})
 
-	mux.HandleFunc("PUT /api/users", func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("PUT /api/users", cfg.middlewareAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		handlePutUsers(w, r, cfg)
+	})))
+
+	mux.Handle("GET /api/users/me", cfg.middlewareAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleGetMe(w, r, cfg)
+	})))
+
+	mux.Handle("PATCH /api/users/me", cfg.middlewareAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleUpdateProfile(w, r, cfg)
+	})))
+
+	mux.Handle("GET /api/users/me/mentions", cfg.middlewareAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleGetMentions(w, r, cfg)
+	})))
+
+	mux.HandleFunc("GET /api/users/{userID}", func(w http.ResponseWriter, r *http.Request) {
+		handleGetUser(w, r, cfg)
 	})
 
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("POST /api/users/{userID}/follow", cfg.middlewareAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleFollowUser(w, r, cfg)
+	})))
+
+	mux.Handle("DELETE /api/users/{userID}/follow", cfg.middlewareAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleUnfollowUser(w, r, cfg)
+	})))
+
+	mux.HandleFunc("GET /api/users/{userID}/followers", func(w http.ResponseWriter, r *http.Request) {
+		handleGetFollowers(w, r, cfg)
+	})
+
+	mux.HandleFunc("GET /api/users/{userID}/following", func(w http.ResponseWriter, r *http.Request) {
+		handleGetFollowing(w, r, cfg)
+	})
+
+	mux.Handle("GET /api/timeline", cfg.middlewareAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleGetTimeline(w, r, cfg)
+	})))
+
+	mux.Handle("DELETE /api/users", cfg.middlewareAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDeleteUser(w, r, cfg)
+	})))
+
+	mux.Handle("GET /api/users/me/export", cfg.middlewareAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleExportUser(w, r, cfg)
+	})))
+
+	mux.Handle("PUT /api/chirps/{chirpID}", cfg.middlewareAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleUpdateChirp(w, r, cfg)
+	})))
+
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", func(w http.ResponseWriter, r *http.Request) {
+		handleGetChirpRevisions(w, r, cfg)
+	})
+
+	mux.Handle("POST /api/chirps/{chirpID}/like", cfg.middlewareAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleLikeChirp(w, r, cfg)
+	})))
+
+	mux.Handle("DELETE /api/chirps/{chirpID}/like", cfg.middlewareAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleUnlikeChirp(w, r, cfg)
+	})))
+
+	mux.Handle("DELETE /api/chirps/{chirpID}", cfg.middlewareAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDeleteChirp(w, r, cfg)
+	})))
+
+	mux.HandleFunc("POST /api/polka/webhooks", func(w http.ResponseWriter, r *http.Request) {
+		handlePolkaWebhook(w, r, cfg)
 	})
 
 	srv := &http.Server{
-		Addr:    ":" + PORT,
-		Handler: mux,
+		Handler:      middlewareLog(cfg.middlewareMetrics(mux)),
+		ReadTimeout:  timeouts.read,
+		WriteTimeout: timeouts.write,
+		IdleTimeout:  timeouts.idle,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	cfg.draining.Store(true)
+	if timeouts.ready > 0 {
+		log.Printf("Shutting down, failing readiness for %s", timeouts.ready)
+		select {
+		case err := <-serveErr:
+			return err
+		case <-time.After(timeouts.ready):
+		}
+	}
+
+	log.Printf("Shutting down, draining connections for up to %s", timeouts.drain)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeouts.drain)
+	defer cancel()
+
+	err := srv.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Printf("Error draining connections: %s", err)
+	}
+
+	if cfg.dbConn != nil {
+		if closeErr := cfg.dbConn.Close(); closeErr != nil {
+			log.Printf("Error closing database: %s", closeErr)
+		}
 	}
 
-	log.Printf("Server runing on: http://localhost:%s\n", PORT)
-	log.Fatal(srv.ListenAndServe())
+	return err
 }
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SzymonJaroslawski/chirpy/internal/auth"
+	"github.com/SzymonJaroslawski/chirpy/internal/filter"
+)
+
+// startTestServer runs serve on a random local port and returns its base URL
+// and a function that shuts it down and returns serve's error.
+func startTestServer(t *testing.T, cfg *apiConfig) (string, func() error) {
+	t.Helper()
+	return startTestServerWithTimeouts(t, cfg, serverTimeouts{drain: 5 * time.Second})
+}
+
+func startTestServerWithTimeouts(t *testing.T, cfg *apiConfig, timeouts serverTimeouts) (string, func() error) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(ctx, cfg, ln, timeouts)
+	}()
+
+	stop := func() error {
+		cancel()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(10 * time.Second):
+			t.Fatal("serve did not return after shutdown")
+			return nil
+		}
+	}
+
+	return "http://" + ln.Addr().String(), stop
+}
+
+func newTestAPIConfig() *apiConfig {
+	cfg := &apiConfig{
+		keys:           auth.NewHMACKeyProvider("secret"),
+		chirpMaxLength: 140,
+		profanity:      filter.New(filter.Static{"kerfuffle", "sharbert", "fornax"}),
+		profanityMode:  filter.ModeMask,
+	}
+	cfg.profanity.Reload(context.Background())
+	cfg.metrics = newMetrics(cfg, nil)
+	return cfg
+}
+
+func TestServeShutdown(t *testing.T) {
+	cfg := newTestAPIConfig()
+	baseURL, stop := startTestServer(t, cfg)
+
+	res, err := http.Get(baseURL + "/api/healthz")
+	if err != nil {
+		t.Fatalf("GET /api/healthz error = %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("GET /api/healthz status = %d, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	if err := stop(); err != nil {
+		t.Errorf("serve() error = %v", err)
+	}
+	if !cfg.draining.Load() {
+		t.Error("serve() did not mark the server as draining")
+	}
+
+	if _, err := http.Get(baseURL + "/api/healthz"); err == nil {
+		t.Error("GET /api/healthz succeeded after shutdown")
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	cfg := newTestAPIConfig()
+	baseURL, stop := startTestServer(t, cfg)
+	defer stop()
+
+	for _, path := range []string{"/api/healthz", "/api/healthz", "/does-not-exist"} {
+		res, err := http.Get(baseURL + path)
+		if err != nil {
+			t.Fatalf("GET %s error = %v", path, err)
+		}
+		res.Body.Close()
+	}
+
+	res, err := http.Get(baseURL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics error = %v", err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+
+	for _, want := range []string{
+		`chirpy_http_requests_total{code="200",method="GET",route="GET /api/healthz"} 2`,
+		`chirpy_http_requests_total{code="404",method="GET",route="unmatched"} 1`,
+		`chirpy_http_request_duration_seconds_count{code="200",method="GET",route="GET /api/healthz"} 2`,
+		`chirpy_chirps_created_total 0`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("GET /metrics missing %q", want)
+		}
+	}
+}
+
+func TestServeShutdownDelay(t *testing.T) {
+	cfg := newTestAPIConfig()
+	baseURL, stop := startTestServerWithTimeouts(t, cfg, serverTimeouts{
+		drain: 5 * time.Second,
+		ready: time.Second,
+	})
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- stop()
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for !cfg.draining.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("serve() did not start draining")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	res, err := http.Get(baseURL + "/api/healthz")
+	if err != nil {
+		t.Fatalf("GET /api/healthz during shutdown delay error = %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("GET /api/healthz status = %d, want %d", res.StatusCode, http.StatusServiceUnavailable)
+	}
+
+	if err := <-stopped; err != nil {
+		t.Errorf("serve() error = %v", err)
+	}
+}
+
+func TestHealthzDraining(t *testing.T) {
+	cfg := &apiConfig{}
+	cfg.draining.Store(true)
+
+	rec := httptest.NewRecorder()
+	handleHealthz(rec, httptest.NewRequest(http.MethodGet, "/api/healthz", nil), cfg)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("handleHealthz() status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
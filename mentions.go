@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/SzymonJaroslawski/chirpy/internal/database"
+)
+
+var mentionPattern = regexp.MustCompile(`@[A-Za-z0-9_]+`)
+
+// parseMentions returns the lowercased handles mentioned in body, each once,
+// in the order they first appear. A mention has to stand on its own, so
+// addresses like jane@example.com and handles longer than a handle can be
+// are left alone.
+func parseMentions(body string) []string {
+	var handles []string
+	seen := map[string]bool{}
+	for _, loc := range mentionPattern.FindAllStringIndex(body, -1) {
+		if loc[0] > 0 && isHandleByte(body[loc[0]-1]) {
+			continue
+		}
+		handle := strings.ToLower(body[loc[0]+1 : loc[1]])
+		if !handlePattern.MatchString(handle) || seen[handle] {
+			continue
+		}
+		seen[handle] = true
+		handles = append(handles, handle)
+	}
+	return handles
+}
+
+func isHandleByte(b byte) bool {
+	return b == '@' || b == '_' || ('0' <= b && b <= '9') || ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z')
+}
+
+// saveMentions links chirp to the users it mentions. Handles nobody has
+// claimed stay plain text.
+func saveMentions(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	for _, handle := range parseMentions(chirp.Body) {
+		err := q.CreateChirpMention(ctx, database.CreateChirpMentionParams{
+			ChirpID: chirp.ID,
+			Handle:  handle,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
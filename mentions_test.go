@@ -0,0 +1,34 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{name: "None", body: "Hello, world!", want: nil},
+		{name: "One", body: "Hi @jane!", want: []string{"jane"}},
+		{name: "Start of chirp", body: "@jane hi", want: []string{"jane"}},
+		{name: "Lowercased and deduplicated", body: "@Jane and @JANE and @bob_1", want: []string{"jane", "bob_1"}},
+		{name: "Email address", body: "Mail jane@example.com", want: nil},
+		{name: "Double at", body: "@@jane", want: nil},
+		{name: "Too long", body: "@" + strings.Repeat("a", 16), want: nil},
+		{name: "Longest handle", body: "@" + strings.Repeat("a", 15), want: []string{strings.Repeat("a", 15)}},
+		{name: "Bare at", body: "meet @ noon", want: nil},
+		{name: "After punctuation", body: "(@jane) cc:@bob", want: []string{"jane", "bob"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseMentions(tt.body); !slices.Equal(got, tt.want) {
+				t.Errorf("parseMentions(%q) = %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
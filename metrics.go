@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/SzymonJaroslawski/chirpy/internal/database"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+type metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests        *prometheus.CounterVec
+	httpRequestDuration *prometheus.HistogramVec
+	dbQueryDuration     *prometheus.HistogramVec
+
+	chirpsCreated prometheus.Counter
+	logins        prometheus.Counter
+	failedLogins  prometheus.Counter
+}
+
+// newMetrics registers chirpy's collectors on a fresh registry. db may be nil,
+// in which case no connection pool gauges are exported.
+func newMetrics(cfg *apiConfig, db *sql.DB) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_http_requests_total",
+			Help: "HTTP requests by route pattern, method and status code.",
+		}, []string{"route", "method", "code"}),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_http_request_duration_seconds",
+			Help:    "HTTP request latency by route pattern, method and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "code"}),
+		dbQueryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_db_query_duration_seconds",
+			Help:    "Database query latency by sqlc query name.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"query"}),
+		chirpsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chirpy_chirps_created_total",
+			Help: "Chirps created.",
+		}),
+		logins: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chirpy_logins_total",
+			Help: "Successful logins.",
+		}),
+		failedLogins: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chirpy_failed_logins_total",
+			Help: "Logins rejected because of an unknown email or a wrong password.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.httpRequests,
+		m.httpRequestDuration,
+		m.dbQueryDuration,
+		m.chirpsCreated,
+		m.logins,
+		m.failedLogins,
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "chirpy_fileserver_hits_total",
+			Help: "Requests served from /app/.",
+		}, func() float64 {
+			return float64(cfg.fileserverHits.Load())
+		}),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, "chirpy"))
+	}
+
+	return m
+}
+
+func (m *metrics) observeRequest(route, method string, code int, duration time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	labels := prometheus.Labels{"route": route, "method": method, "code": strconv.Itoa(code)}
+	m.httpRequests.With(labels).Inc()
+	m.httpRequestDuration.With(labels).Observe(duration.Seconds())
+}
+
+// sqlcQueryName matches the "-- name: CreateChirp :one" header sqlc puts at
+// the start of every generated query.
+var sqlcQueryName = regexp.MustCompile(`^-- name: (\w+)`)
+
+// instrumentedDB times every query issued through the sqlc Queries and labels
+// it with the sqlc method name.
+type instrumentedDB struct {
+	db      database.DBTX
+	metrics *metrics
+}
+
+func (i instrumentedDB) observe(query string, start time.Time) {
+	name := "unknown"
+	if match := sqlcQueryName.FindStringSubmatch(query); match != nil {
+		name = match[1]
+	}
+	i.metrics.dbQueryDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+}
+
+func (i instrumentedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer i.observe(query, time.Now())
+	return i.db.ExecContext(ctx, query, args...)
+}
+
+func (i instrumentedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return i.db.PrepareContext(ctx, query)
+}
+
+func (i instrumentedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer i.observe(query, time.Now())
+	return i.db.QueryContext(ctx, query, args...)
+}
+
+func (i instrumentedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer i.observe(query, time.Now())
+	return i.db.QueryRowContext(ctx, query, args...)
+}
+
+// queriesWithTx is cfg.db.WithTx that keeps the query instrumentation.
+func (cfg *apiConfig) queriesWithTx(tx *sql.Tx) *database.Queries {
+	return database.New(instrumentedDB{db: tx, metrics: cfg.metrics})
+}
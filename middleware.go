@@ -1,10 +1,27 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
 	"log"
+	"log/slog"
 	"net/http"
+	"time"
+
+	"github.com/SzymonJaroslawski/chirpy/internal/auth"
+	"github.com/google/uuid"
 )
 
+type contextKey string
+
+const userIDContextKey contextKey = "userID"
+
+// userIDFromContext returns the ID of the user authenticated by middlewareAuth.
+func userIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	userID, ok := ctx.Value(userIDContextKey).(uuid.UUID)
+	return userID, ok
+}
+
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		cfg.fileserverHits.Add(1)
@@ -13,9 +30,163 @@ func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	})
 }
 
+// middlewareLog assigns every request an ID, echoes it in X-Request-ID and
+// logs one JSON line per request once it has been served. It must wrap the
+// ServeMux so the matched route pattern is known.
 func middlewareLog(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s", r.Method, r.URL.Path)
+		start := time.Now()
+
+		info := &requestInfo{id: r.Header.Get(REQUEST_ID_HEADER)}
+		if !validRequestID(info.id) {
+			info.id = uuid.NewString()
+		}
+		w.Header().Set(REQUEST_ID_HEADER, info.id)
+		r = r.WithContext(context.WithValue(r.Context(), requestInfoContextKey, info))
+
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("route", r.Pattern),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Int("bytes", rec.bytes),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("remote_ip", remoteIP(r)),
+		}
+		if info.userID != uuid.Nil {
+			attrs = append(attrs, slog.String("user_id", info.userID.String()))
+		}
+		slog.LogAttrs(r.Context(), slog.LevelInfo, "request", attrs...)
+	})
+}
+
+// middlewareAuth rejects requests without a valid access token, or with one
+// for a user that has since been deleted, and stores the token's user ID in
+// the request context for the wrapped handler.
+func (cfg *apiConfig) middlewareAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			respondWithError(w, r, errUnauthorized())
+			return
+		}
+
+		userID, err := cfg.validateAccessToken(token)
+		if err != nil {
+			respondWithError(w, r, errUnauthorized())
+			return
+		}
+
+		active, err := cfg.userIsActive(r, userID)
+		if err != nil {
+			respondWithError(w, r, err)
+			return
+		}
+		if !active {
+			respondWithError(w, r, errUnauthorized())
+			return
+		}
+
+		if info, ok := r.Context().Value(requestInfoContextKey).(*requestInfo); ok {
+			info.userID = userID
+		}
+
+		ctx := context.WithValue(r.Context(), userIDContextKey, userID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// middlewareOptionalAuth is middlewareAuth for endpoints anyone can use. A
+// valid access token identifies the caller; requests without one, or with one
+// that doesn't validate or belongs to a deleted user, are served anonymously.
+func (cfg *apiConfig) middlewareOptionalAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		userID, err := cfg.validateAccessToken(token)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		active, err := cfg.userIsActive(r, userID)
+		if err != nil || !active {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if info, ok := r.Context().Value(requestInfoContextKey).(*requestInfo); ok {
+			info.userID = userID
+		}
+
+		ctx := context.WithValue(r.Context(), userIDContextKey, userID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// middlewareAdmin only lets through requests that carry the admin API key as
+// "Authorization: ApiKey <key>". Without a configured key every request is
+// rejected.
+func (cfg *apiConfig) middlewareAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey, err := auth.GetAPIKey(r.Header)
+		if err != nil || cfg.adminKey == "" || subtle.ConstantTimeCompare([]byte(apiKey), []byte(cfg.adminKey)) != 1 {
+			respondWithError(w, r, errUnauthorized())
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
+
+// responseRecorder remembers the status code and body size written by the
+// wrapped handler.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *responseRecorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// middlewareMetrics records the count and latency of every request. It must
+// wrap the ServeMux so the matched route pattern is known once the request
+// has been served.
+func (cfg *apiConfig) middlewareMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		cfg.metrics.observeRequest(r.Pattern, r.Method, rec.status, time.Since(start))
+	})
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SzymonJaroslawski/chirpy/internal/auth"
+	"github.com/SzymonJaroslawski/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestMiddlewareAuth(t *testing.T) {
+	cfg := newTestAPIConfig()
+	cfg.accessTokenTTL = time.Hour
+	db := newFakeDB(t, cfg)
+	userID := db.addUser(database.User{}).ID
+	deletedID := db.addUser(database.User{DeletedAt: sql.NullTime{Time: time.Now(), Valid: true}}).ID
+	validToken, _ := cfg.makeAccessToken(userID)
+	otherToken, _ := auth.MakeJWT(userID, "other_secret", time.Hour)
+	deletedToken, _ := cfg.makeAccessToken(deletedID)
+	unknownToken, _ := cfg.makeAccessToken(uuid.New())
+
+	tests := []struct {
+		name       string
+		header     string
+		algorithms []string
+		wantStatus int
+	}{
+		{name: "Valid token", header: "Bearer " + validToken, wantStatus: http.StatusOK},
+		{name: "Missing header", header: "", wantStatus: http.StatusUnauthorized},
+		{name: "Malformed header", header: "Token " + validToken, wantStatus: http.StatusUnauthorized},
+		{name: "Wrong secret", header: "Bearer " + otherToken, wantStatus: http.StatusUnauthorized},
+		{name: "Deleted user", header: "Bearer " + deletedToken, wantStatus: http.StatusUnauthorized},
+		{name: "Unknown user", header: "Bearer " + unknownToken, wantStatus: http.StatusUnauthorized},
+		{name: "Algorithm not allowed", header: "Bearer " + validToken, algorithms: []string{"RS256", "EdDSA"}, wantStatus: http.StatusUnauthorized},
+		{name: "Algorithm allowed", header: "Bearer " + validToken, algorithms: []string{"HS256"}, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.jwtAlgorithms = tt.algorithms
+			var gotUserID uuid.UUID
+			handler := cfg.middlewareAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotUserID, _ = userIDFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("middlewareAuth() status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && gotUserID != userID {
+				t.Errorf("userIDFromContext() = %v, want %v", gotUserID, userID)
+			}
+		})
+	}
+}
+
+func TestMiddlewareOptionalAuth(t *testing.T) {
+	cfg := newTestAPIConfig()
+	cfg.accessTokenTTL = time.Hour
+	db := newFakeDB(t, cfg)
+	userID := db.addUser(database.User{}).ID
+	deletedID := db.addUser(database.User{DeletedAt: sql.NullTime{Time: time.Now(), Valid: true}}).ID
+	validToken, _ := cfg.makeAccessToken(userID)
+	otherToken, _ := auth.MakeJWT(userID, "other_secret", time.Hour)
+	deletedToken, _ := cfg.makeAccessToken(deletedID)
+
+	tests := []struct {
+		name       string
+		header     string
+		wantUserID uuid.UUID
+	}{
+		{name: "Valid token", header: "Bearer " + validToken, wantUserID: userID},
+		{name: "Missing header", header: ""},
+		{name: "Malformed header", header: "Token " + validToken},
+		{name: "Wrong secret", header: "Bearer " + otherToken},
+		{name: "Deleted user", header: "Bearer " + deletedToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotUserID uuid.UUID
+			handler := cfg.middlewareOptionalAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotUserID, _ = userIDFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Errorf("middlewareOptionalAuth() status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			if gotUserID != tt.wantUserID {
+				t.Errorf("userIDFromContext() = %v, want %v", gotUserID, tt.wantUserID)
+			}
+		})
+	}
+}
+
+func TestMiddlewareAdmin(t *testing.T) {
+	tests := []struct {
+		name       string
+		adminKey   string
+		header     string
+		wantStatus int
+	}{
+		{name: "Valid key", adminKey: "admin-key", header: "ApiKey admin-key", wantStatus: http.StatusOK},
+		{name: "Wrong key", adminKey: "admin-key", header: "ApiKey other-key", wantStatus: http.StatusUnauthorized},
+		{name: "Bearer token", adminKey: "admin-key", header: "Bearer admin-key", wantStatus: http.StatusUnauthorized},
+		{name: "Missing header", adminKey: "admin-key", wantStatus: http.StatusUnauthorized},
+		{name: "No key configured", header: "ApiKey ", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &apiConfig{adminKey: tt.adminKey}
+			handler := cfg.middlewareAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/banned-words", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("middlewareAdmin() status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+//go:embed sql/schema/*.sql
+var embedMigrations embed.FS
+
+// newMigrationProvider returns a goose provider for the embedded sql/schema
+// migrations. It holds a Postgres advisory lock while migrating so replicas
+// starting at the same time apply each migration only once.
+func newMigrationProvider(db *sql.DB) (*goose.Provider, error) {
+	migrations, err := fs.Sub(embedMigrations, "sql/schema")
+	if err != nil {
+		return nil, err
+	}
+
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, err
+	}
+
+	return goose.NewProvider(goose.DialectPostgres, db, migrations, goose.WithSessionLocker(locker))
+}
+
+// runMigrations implements "chirpy migrate up|down|status".
+func runMigrations(ctx context.Context, db *sql.DB, command string) error {
+	provider, err := newMigrationProvider(db)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "up":
+		results, err := provider.Up(ctx)
+		for _, result := range results {
+			log.Printf("Migrated up: %s (%s)", result.Source.Path, result.Duration)
+		}
+		if err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			log.Printf("No pending migrations")
+		}
+	case "down":
+		result, err := provider.Down(ctx)
+		if err != nil {
+			return err
+		}
+		log.Printf("Migrated down: %s (%s)", result.Source.Path, result.Duration)
+	case "status":
+		statuses, err := provider.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			appliedAt := "Pending"
+			if status.State == goose.StateApplied {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%-20s %s\n", appliedAt, status.Source.Path)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", command)
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	embedded, err := fs.Glob(embedMigrations, "sql/schema/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	onDisk, err := filepath.Glob(filepath.Join("sql", "schema", "*.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(embedded) == 0 || len(embedded) != len(onDisk) {
+		t.Fatalf("embedded %d migrations, %d on disk", len(embedded), len(onDisk))
+	}
+
+	for _, path := range embedded {
+		want, err := os.ReadFile(filepath.FromSlash(path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := embedMigrations.ReadFile(path)
+		if string(got) != string(want) {
+			t.Errorf("embedded %s differs from disk", path)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SzymonJaroslawski/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	DEFAULT_PAGE_SIZE = 20
+	MAX_PAGE_SIZE     = 100
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// pageCursor is the (created_at, id) position of the last row of a page.
+type pageCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+func encodeCursor(c pageCursor) string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return pageCursor{}, errInvalidCursor
+	}
+
+	createdAtString, idString, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return pageCursor{}, errInvalidCursor
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtString)
+	if err != nil {
+		return pageCursor{}, errInvalidCursor
+	}
+
+	id, err := uuid.Parse(idString)
+	if err != nil {
+		return pageCursor{}, errInvalidCursor
+	}
+
+	return pageCursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+// parseCursorParam reads ?cursor= into the nullable arguments the list
+// queries take. Both are NULL on the first page.
+func parseCursorParam(query url.Values) (sql.NullTime, uuid.NullUUID, error) {
+	s := query.Get("cursor")
+	if s == "" {
+		return sql.NullTime{}, uuid.NullUUID{}, nil
+	}
+
+	cursor, err := decodeCursor(s)
+	if err != nil {
+		return sql.NullTime{}, uuid.NullUUID{}, err
+	}
+
+	return sql.NullTime{Time: cursor.CreatedAt, Valid: true}, uuid.NullUUID{UUID: cursor.ID, Valid: true}, nil
+}
+
+// parsePageLimit reads ?limit= and clamps it to MAX_PAGE_SIZE.
+func parsePageLimit(query url.Values) (int, error) {
+	s := query.Get("limit")
+	if s == "" {
+		return DEFAULT_PAGE_SIZE, nil
+	}
+
+	limit, err := strconv.Atoi(s)
+	if err != nil || limit < 1 {
+		return 0, errors.New("invalid limit")
+	}
+
+	return min(limit, MAX_PAGE_SIZE), nil
+}
+
+// pageRequest is the page a list endpoint was asked for through ?limit= and
+// ?cursor=. The cursor fields are NULL on the first page.
+type pageRequest struct {
+	Limit           int
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+}
+
+// parsePageRequest reads ?limit= and ?cursor=, returning the API error to
+// send back when either is invalid.
+func parsePageRequest(query url.Values) (pageRequest, error) {
+	limit, err := parsePageLimit(query)
+	if err != nil {
+		return pageRequest{}, errInvalidParameter("limit", "Invalid limit, expected a positive integer")
+	}
+
+	cursorCreatedAt, cursorID, err := parseCursorParam(query)
+	if err != nil {
+		return pageRequest{}, errInvalidParameter("cursor", "Invalid cursor")
+	}
+
+	return pageRequest{Limit: limit, CursorCreatedAt: cursorCreatedAt, CursorID: cursorID}, nil
+}
+
+// fetchLimit is the row limit to query with: one more than the page size, so
+// paginate can tell whether there is a next page.
+func (p pageRequest) fetchLimit() int32 {
+	return int32(p.Limit + 1)
+}
+
+// paginate trims rows fetched with fetchLimit to the page and returns the
+// cursor of the next page, or "" when this is the last one. position gives
+// the cursor of a row.
+func paginate[Row any](rows []Row, p pageRequest, position func(Row) pageCursor) ([]Row, string) {
+	if len(rows) <= p.Limit {
+		return rows, ""
+	}
+
+	rows = rows[:p.Limit]
+	return rows, encodeCursor(position(rows[len(rows)-1]))
+}
+
+func chirpPosition(chirp database.Chirp) pageCursor {
+	return pageCursor{CreatedAt: chirp.CreatedAt, ID: chirp.ID}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/SzymonJaroslawski/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	want := pageCursor{
+		CreatedAt: time.Date(2024, 12, 1, 10, 30, 0, 123456000, time.UTC),
+		ID:        uuid.New(),
+	}
+
+	got, err := decodeCursor(encodeCursor(want))
+	if err != nil {
+		t.Fatalf("decodeCursor() error = %v", err)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID {
+		t.Errorf("decodeCursor() = %v, want %v", got, want)
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "Not base64", cursor: "!!!"},
+		{name: "Missing separator", cursor: "bm9zZXBhcmF0b3I"},
+		{name: "Bad time", cursor: "bm90LWEtdGltZXwwMDAwMDAwMC0wMDAwLTAwMDAtMDAwMC0wMDAwMDAwMDAwMDA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.cursor); err == nil {
+				t.Errorf("decodeCursor(%q) expected error", tt.cursor)
+			}
+		})
+	}
+}
+
+func TestParsePageLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   string
+		want    int
+		wantErr bool
+	}{
+		{name: "Default", limit: "", want: DEFAULT_PAGE_SIZE},
+		{name: "Explicit", limit: "5", want: 5},
+		{name: "Clamped", limit: "1000", want: MAX_PAGE_SIZE},
+		{name: "Zero", limit: "0", wantErr: true},
+		{name: "Not a number", limit: "ten", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := url.Values{}
+			if tt.limit != "" {
+				query.Set("limit", tt.limit)
+			}
+			got, err := parsePageLimit(query)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parsePageLimit() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parsePageLimit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePageRequest(t *testing.T) {
+	cursor := pageCursor{CreatedAt: time.Date(2024, 12, 1, 10, 30, 0, 0, time.UTC), ID: uuid.New()}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantLimit int
+		wantParam string
+	}{
+		{name: "First page", query: "", wantLimit: DEFAULT_PAGE_SIZE},
+		{name: "Next page", query: "limit=5&cursor=" + encodeCursor(cursor), wantLimit: 5},
+		{name: "Invalid limit", query: "limit=0", wantParam: "limit"},
+		{name: "Invalid cursor", query: "cursor=nope", wantParam: "cursor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			page, err := parsePageRequest(query)
+			if tt.wantParam != "" {
+				var apiErr *apiError
+				if !errors.As(err, &apiErr) || apiErr.Details["parameter"] != tt.wantParam {
+					t.Errorf("parsePageRequest() error = %v, want invalid %q", err, tt.wantParam)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePageRequest() error = %v", err)
+			}
+			if page.Limit != tt.wantLimit || page.fetchLimit() != int32(tt.wantLimit+1) {
+				t.Errorf("parsePageRequest() = %+v, want limit %d", page, tt.wantLimit)
+			}
+			if page.CursorID.Valid != query.Has("cursor") || (page.CursorID.Valid && page.CursorID.UUID != cursor.ID) {
+				t.Errorf("parsePageRequest() cursor = %v, want %v", page.CursorID, cursor.ID)
+			}
+		})
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	chirps := make([]database.Chirp, 4)
+	for i := range chirps {
+		chirps[i] = database.Chirp{ID: uuid.New(), CreatedAt: time.Date(2024, 12, i+1, 0, 0, 0, 0, time.UTC)}
+	}
+
+	tests := []struct {
+		name     string
+		rows     int
+		limit    int
+		wantRows int
+		wantNext bool
+	}{
+		{name: "Empty", rows: 0, limit: 3, wantRows: 0},
+		{name: "Short page", rows: 2, limit: 3, wantRows: 2},
+		{name: "Exactly one page", rows: 3, limit: 3, wantRows: 3},
+		{name: "More pages", rows: 4, limit: 3, wantRows: 3, wantNext: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, next := paginate(chirps[:tt.rows], pageRequest{Limit: tt.limit}, chirpPosition)
+			if len(rows) != tt.wantRows {
+				t.Errorf("paginate() returned %d rows, want %d", len(rows), tt.wantRows)
+			}
+			if (next != "") != tt.wantNext {
+				t.Fatalf("paginate() next cursor = %q, want one: %v", next, tt.wantNext)
+			}
+			if next == "" {
+				return
+			}
+
+			cursor, err := decodeCursor(next)
+			last := rows[len(rows)-1]
+			if err != nil || cursor.ID != last.ID || !cursor.CreatedAt.Equal(last.CreatedAt) {
+				t.Errorf("next cursor = %+v, %v, want the last row %v", cursor, err, last.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/SzymonJaroslawski/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// softDeleteUser hides a user and their chirps and revokes their refresh
+// tokens. Their access tokens are turned away by middlewareAuth. The chirps
+// get the user's deletion time so restoring the user brings back only those,
+// not the ones deleted on their own before.
+func (cfg *apiConfig) softDeleteUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.User{}, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
+
+	user, err := qtx.SoftDeleteUser(ctx, id)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	err = qtx.SoftDeleteUserChirps(ctx, id)
+	if err != nil {
+		return database.User{}, fmt.Errorf("deleting chirps: %w", err)
+	}
+
+	err = qtx.RevokeUserRefreshTokens(ctx, id)
+	if err != nil {
+		return database.User{}, fmt.Errorf("revoking refresh tokens: %w", err)
+	}
+
+	return user, tx.Commit()
+}
+
+// deleteUser removes a user for good, along with everything that cascades
+// from them: chirps and their revisions, likes, follows, mentions and refresh
+// tokens. It is what users get when they delete their own account, so none
+// of their personal data is kept around. It returns sql.ErrNoRows when there
+// is no such user.
+func (cfg *apiConfig) deleteUser(ctx context.Context, id uuid.UUID) error {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
+
+	// Locking the user blocks new likes until the delete commits, so none
+	// can slip in after the counters are adjusted and then cascade away.
+	_, err = qtx.GetUserForUpdate(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// Deleting the user cascades to their likes but leaves like_count alone,
+	// so adjust the chirps they liked first.
+	err = qtx.SubtractUserLikes(ctx, id)
+	if err != nil {
+		return fmt.Errorf("subtracting likes: %w", err)
+	}
+
+	deleted, err := qtx.DeleteUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+
+	return tx.Commit()
+}
+
+// restoreUser undoes softDeleteUser if it happened within the retention
+// window. It returns sql.ErrNoRows when there is nothing to restore.
+func (cfg *apiConfig) restoreUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.User{}, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
+
+	// The chirps are matched on the user's deletion time, so they have to
+	// go first.
+	err = qtx.RestoreUserChirps(ctx, id)
+	if err != nil {
+		return database.User{}, fmt.Errorf("restoring chirps: %w", err)
+	}
+
+	user, err := qtx.RestoreUser(ctx, database.RestoreUserParams{
+		ID:               id,
+		RetentionSeconds: cfg.deletedRetention.Seconds(),
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+
+	return user, tx.Commit()
+}
+
+// purgeDeleted hard-deletes users and chirps that were deleted longer ago
+// than the retention window.
+func (cfg *apiConfig) purgeDeleted(ctx context.Context) (chirps, users int64, err error) {
+	retention := cfg.deletedRetention.Seconds()
+
+	chirps, err = cfg.db.PurgeDeletedChirps(ctx, retention)
+	if err != nil {
+		return 0, 0, fmt.Errorf("purging chirps: %w", err)
+	}
+
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return chirps, 0, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := cfg.queriesWithTx(tx)
+
+	// Purged users' likes cascade away below, so take them off the counters
+	// of the chirps they liked while they can still be counted.
+	err = qtx.SubtractPurgedUserLikes(ctx, retention)
+	if err != nil {
+		return chirps, 0, fmt.Errorf("subtracting likes of purged users: %w", err)
+	}
+
+	users, err = qtx.PurgeDeletedUsers(ctx, retention)
+	if err != nil {
+		return chirps, 0, fmt.Errorf("purging users: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return chirps, 0, fmt.Errorf("commiting purge: %w", err)
+	}
+
+	return chirps, users, nil
+}
+
+// runPurger calls purgeDeleted every interval until ctx is canceled.
+func runPurger(ctx context.Context, cfg *apiConfig, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		purgeCtx, cancel := context.WithTimeout(ctx, cfg.dbTimeout)
+		chirps, users, err := cfg.purgeDeleted(purgeCtx)
+		cancel()
+		if err != nil {
+			slog.Error("Error purging deleted rows", "error", err)
+			continue
+		}
+		if chirps > 0 || users > 0 {
+			slog.Info("Purged deleted rows", "chirps", chirps, "users", users)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+-- name: ListBannedWords :many
+SELECT * FROM banned_words
+ORDER BY lower(word);
+
+-- name: CreateBannedWord :one
+INSERT INTO banned_words (created_at, word)
+VALUES (
+  NOW(),
+  $1
+)
+ON CONFLICT (lower(word)) DO NOTHING
+RETURNING *;
+
+-- name: DeleteBannedWord :execrows
+DELETE FROM banned_words
+WHERE lower(word) = lower($1);
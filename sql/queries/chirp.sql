@@ -1,17 +1,96 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (created_at, updated_at, body, user_id)
-VALUES (
-  NOW(),
-  NOW(),
-  $1,
-  $2
-)
+INSERT INTO chirps (created_at, updated_at, body, user_id, flagged)
+SELECT NOW(), NOW(), $1, users.id, $3
+FROM users
+WHERE users.id = $2 AND users.deleted_at IS NULL
 RETURNING *;
 
 -- name: GetChirpWithId :one
 SELECT * FROM chirps 
-WHERE id = $1;
+WHERE id = $1 AND deleted_at IS NULL;
 
 -- name: GetAllChirps :many
 SELECT * FROM chirps
+WHERE deleted_at IS NULL
 ORDER BY created_at ASC;
+
+-- name: SoftDeleteChirp :execrows
+UPDATE chirps
+SET deleted_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: ListChirpsAsc :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+  AND (sqlc.narg('user_id')::uuid IS NULL OR user_id = sqlc.narg('user_id'))
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid)
+  )
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListChirpsDesc :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+  AND (sqlc.narg('user_id')::uuid IS NULL OR user_id = sqlc.narg('user_id'))
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid)
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: GetChirpForUpdate :one
+SELECT * FROM chirps
+WHERE id = $1 AND deleted_at IS NULL
+FOR UPDATE;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, flagged = $3, updated_at = NOW(), edited_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: SoftDeleteUserChirps :exec
+UPDATE chirps
+SET deleted_at = users.deleted_at
+FROM users
+WHERE chirps.user_id = users.id
+  AND users.id = $1
+  AND chirps.deleted_at IS NULL;
+
+-- name: RestoreUserChirps :exec
+UPDATE chirps
+SET deleted_at = NULL
+FROM users
+WHERE chirps.user_id = users.id
+  AND users.id = $1
+  AND chirps.deleted_at = users.deleted_at;
+
+-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL
+WHERE id = sqlc.arg('id')
+  AND deleted_at > NOW() - make_interval(secs => sqlc.arg('retention_seconds')::float8)
+  AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+RETURNING *;
+
+-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at < NOW() - make_interval(secs => sqlc.arg('retention_seconds')::float8);
+
+-- name: ListUserChirps :many
+SELECT * FROM chirps
+WHERE user_id = $1 AND deleted_at IS NULL
+ORDER BY created_at ASC, id ASC;
+
+-- name: CountUserChirps :one
+SELECT count(*) FROM chirps
+WHERE user_id = $1 AND deleted_at IS NULL;
+
+-- name: AdjustChirpLikeCount :one
+UPDATE chirps
+SET like_count = like_count + sqlc.arg('delta')
+WHERE id = sqlc.arg('id')
+RETURNING *;
@@ -0,0 +1,32 @@
+-- name: CreateChirpLike :execrows
+INSERT INTO chirp_likes (chirp_id, user_id, created_at)
+SELECT $1, users.id, NOW()
+FROM users
+WHERE users.id = $2 AND users.deleted_at IS NULL
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteChirpLike :execrows
+DELETE FROM chirp_likes
+WHERE chirp_id = $1 AND user_id = $2;
+
+-- name: ListLikedChirpIDs :many
+SELECT chirp_id FROM chirp_likes
+WHERE user_id = sqlc.arg('user_id')
+  AND chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[]);
+
+-- name: SubtractPurgedUserLikes :exec
+UPDATE chirps
+SET like_count = chirps.like_count - purged.likes
+FROM (
+  SELECT chirp_likes.chirp_id, count(*) AS likes FROM chirp_likes
+  JOIN users ON users.id = chirp_likes.user_id
+  WHERE users.deleted_at < NOW() - make_interval(secs => sqlc.arg('retention_seconds')::float8)
+  GROUP BY chirp_likes.chirp_id
+) AS purged
+WHERE chirps.id = purged.chirp_id;
+
+-- name: SubtractUserLikes :exec
+UPDATE chirps
+SET like_count = chirps.like_count - 1
+FROM chirp_likes
+WHERE chirp_likes.chirp_id = chirps.id AND chirp_likes.user_id = $1;
@@ -0,0 +1,18 @@
+-- name: CreateChirpRevision :exec
+INSERT INTO chirp_revisions (created_at, chirp_id, body)
+VALUES (
+  NOW(),
+  $1,
+  $2
+);
+
+-- name: ListChirpRevisions :many
+SELECT * FROM chirp_revisions
+WHERE chirp_id = $1
+ORDER BY created_at DESC, id DESC;
+
+-- name: ListUserChirpRevisions :many
+SELECT chirp_revisions.* FROM chirp_revisions
+JOIN chirps ON chirps.id = chirp_revisions.chirp_id
+WHERE chirps.user_id = $1 AND chirps.deleted_at IS NULL
+ORDER BY chirp_revisions.created_at DESC, chirp_revisions.id DESC;
@@ -13,10 +13,58 @@ DELETE FROM users;
 
 -- name: GetUserWithEmail :one
 SELECT * FROM users 
-WHERE email = $1;
+WHERE email = $1 AND deleted_at IS NULL;
 
 -- name: UpdateUserEmailAndPassword :one 
 UPDATE users 
 SET email = $1, hashed_passowrd = $2
-WHERE id = $3
+WHERE id = $3 AND deleted_at IS NULL
 RETURNING *;
+
+-- name: UpgradeUserToChirpyRed :one
+UPDATE users
+SET is_chirpy_red = true, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: SoftDeleteUser :one
+UPDATE users
+SET deleted_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: RestoreUser :one
+UPDATE users
+SET deleted_at = NULL
+WHERE id = sqlc.arg('id')
+  AND deleted_at > NOW() - make_interval(secs => sqlc.arg('retention_seconds')::float8)
+RETURNING *;
+
+-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deleted_at < NOW() - make_interval(secs => sqlc.arg('retention_seconds')::float8);
+
+-- name: GetUserWithId :one
+SELECT * FROM users
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: GetUserForUpdate :one
+SELECT * FROM users
+WHERE id = $1 AND deleted_at IS NULL
+FOR UPDATE;
+
+-- name: UpdateUserProfile :one
+UPDATE users
+SET handle = $2, display_name = $3, bio = $4, avatar_url = $5, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: UserIsActive :one
+SELECT EXISTS (
+  SELECT 1 FROM users
+  WHERE id = $1 AND deleted_at IS NULL
+);
+
+-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE id = $1 AND deleted_at IS NULL;
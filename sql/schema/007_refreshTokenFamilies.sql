@@ -0,0 +1,13 @@
+-- +goose Up 
+ALTER TABLE refresh_tokens
+ADD family_id UUID DEFAULT gen_random_uuid() NOT NULL,
+ADD replaced_by TEXT REFERENCES refresh_tokens(token) ON DELETE SET NULL;
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+
+-- +goose Down 
+DROP INDEX refresh_tokens_family_id_idx;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN replaced_by,
+DROP COLUMN family_id;
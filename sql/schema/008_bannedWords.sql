@@ -0,0 +1,14 @@
+-- +goose Up 
+CREATE TABLE banned_words (
+  id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  word TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX banned_words_lower_word_idx ON banned_words (lower(word));
+
+INSERT INTO banned_words (created_at, word)
+VALUES (NOW(), 'kerfuffle'), (NOW(), 'sharbert'), (NOW(), 'fornax');
+
+-- +goose Down 
+DROP TABLE banned_words;
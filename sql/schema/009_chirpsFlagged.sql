@@ -0,0 +1,7 @@
+-- +goose Up 
+ALTER TABLE chirps
+ADD flagged BOOLEAN DEFAULT false NOT NULL;
+
+-- +goose Down 
+ALTER TABLE chirps
+DROP COLUMN flagged;
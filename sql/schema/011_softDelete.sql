@@ -0,0 +1,19 @@
+-- +goose Up 
+ALTER TABLE users
+ADD deleted_at TIMESTAMP;
+
+ALTER TABLE chirps
+ADD deleted_at TIMESTAMP;
+
+CREATE INDEX users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX chirps_deleted_at_idx ON chirps (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down 
+DROP INDEX chirps_deleted_at_idx;
+DROP INDEX users_deleted_at_idx;
+
+ALTER TABLE chirps
+DROP COLUMN deleted_at;
+
+ALTER TABLE users
+DROP COLUMN deleted_at;
@@ -0,0 +1,13 @@
+-- +goose Up 
+ALTER TABLE users
+ADD handle TEXT,
+ADD display_name TEXT,
+ADD bio TEXT,
+ADD avatar_url TEXT;
+
+-- +goose Down 
+ALTER TABLE users
+DROP COLUMN avatar_url,
+DROP COLUMN bio,
+DROP COLUMN display_name,
+DROP COLUMN handle;
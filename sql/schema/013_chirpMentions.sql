@@ -0,0 +1,16 @@
+-- +goose Up 
+CREATE UNIQUE INDEX users_handle_lower_idx ON users (lower(handle));
+
+CREATE TABLE chirp_mentions (
+  chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (chirp_id, user_id)
+);
+
+CREATE INDEX chirp_mentions_user_id_idx ON chirp_mentions (user_id);
+
+-- +goose Down 
+DROP TABLE chirp_mentions;
+
+DROP INDEX users_handle_lower_idx;
@@ -0,0 +1,18 @@
+-- +goose Up 
+ALTER TABLE chirps
+ADD like_count INTEGER DEFAULT 0 NOT NULL;
+
+CREATE TABLE chirp_likes (
+  chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (chirp_id, user_id)
+);
+
+CREATE INDEX chirp_likes_user_id_idx ON chirp_likes (user_id);
+
+-- +goose Down 
+DROP TABLE chirp_likes;
+
+ALTER TABLE chirps
+DROP COLUMN like_count;
@@ -0,0 +1,8 @@
+-- +goose Up 
+-- The length limit is CHIRP_MAX_LENGTH, enforced by the application.
+ALTER TABLE chirps
+ALTER COLUMN body TYPE TEXT;
+
+-- +goose Down 
+ALTER TABLE chirps
+ALTER COLUMN body TYPE VARCHAR(140);
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/SzymonJaroslawski/chirpy/internal/database"
+	"github.com/SzymonJaroslawski/chirpy/internal/filter"
+)
+
+const (
+	MAX_DISPLAY_NAME_LENGTH = 50
+	MAX_BIO_LENGTH          = 160
+	MAX_AVATAR_URL_LENGTH   = 2048
+)
+
+// handlePattern is what a handle may look like, without the leading @.
+var handlePattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,15}$`)
+
+// validatedChirp is a chirp body that is ready to be stored.
+type validatedChirp struct {
+	Body    string
+	Flagged bool
+	// Words are the banned words found in the body as written.
+	Words []string
+}
+
+// validateChirp is the pipeline every chirp body goes through before it is
+// accepted. Control characters are stripped and surrounding whitespace is
+// trimmed, blank and over-long chirps are rejected and banned words are
+// handled according to the configured mode. Length is counted in characters
+// against CHIRP_MAX_LENGTH; the TEXT column it is stored in has no limit of
+// its own.
+func (cfg *apiConfig) validateChirp(body string) (validatedChirp, error) {
+	body = strings.TrimSpace(stripControl(body))
+	if body == "" {
+		return validatedChirp{}, newAPIError(http.StatusBadRequest, CODE_EMPTY_CHIRP, "Chirp is empty")
+	}
+
+	if utf8.RuneCountInString(body) > cfg.chirpMaxLength {
+		return validatedChirp{}, errChirpTooLong(cfg.chirpMaxLength)
+	}
+
+	result := cfg.profanity.Check(body)
+	if result.Clean() {
+		return validatedChirp{Body: body}, nil
+	}
+
+	switch cfg.profanityMode {
+	case filter.ModeReject:
+		return validatedChirp{}, errProfanity(result.Words())
+	case filter.ModeFlag:
+		return validatedChirp{Body: body, Flagged: true, Words: result.Words()}, nil
+	default:
+		return validatedChirp{Body: result.Masked, Words: result.Words()}, nil
+	}
+}
+
+// stripControl removes control characters except newlines, bidi controls
+// that could reorder the text around them and invisible characters that
+// could hide a banned word from the filter. The zero width joiner stays
+// because emoji sequences need it. Tabs become spaces so the words on either
+// side stay apart, and invalid UTF-8 becomes U+FFFD.
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n':
+			return r
+		case r == '\t':
+			return ' '
+		case unicode.IsControl(r), unicode.Is(unicode.Bidi_Control, r):
+			return -1
+		case r == '\u200b', r == '\u200c', r == '\u2060', r == '\ufeff':
+			// Zero width space, non-joiner, word joiner and byte order mark.
+			return -1
+		default:
+			return r
+		}
+	}, s)
+}
+
+// profileUpdate is a PATCH to the profile. Fields left out are unchanged and
+// empty strings clear them.
+type profileUpdate struct {
+	Handle      *string `json:"handle"`
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+	AvatarURL   *string `json:"avatar_url"`
+}
+
+// apply validates the update and merges it into the user's current profile.
+func (p profileUpdate) apply(user database.User) (database.UpdateUserProfileParams, error) {
+	params := database.UpdateUserProfileParams{
+		ID:          user.ID,
+		Handle:      user.Handle,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarUrl:   user.AvatarUrl,
+	}
+
+	if p.Handle != nil {
+		handle := strings.TrimPrefix(strings.TrimSpace(*p.Handle), "@")
+		if handle != "" && !handlePattern.MatchString(handle) {
+			return params, errInvalidParameter("handle", "Handle must be 1 to 15 letters, digits or underscores")
+		}
+		params.Handle = optionalString(handle)
+	}
+
+	if p.DisplayName != nil {
+		// Display names are shown on one line.
+		name := strings.Join(strings.Fields(stripControl(*p.DisplayName)), " ")
+		if utf8.RuneCountInString(name) > MAX_DISPLAY_NAME_LENGTH {
+			return params, errInvalidParameter("display_name", fmt.Sprintf("Display name must be at most %d characters", MAX_DISPLAY_NAME_LENGTH))
+		}
+		params.DisplayName = optionalString(name)
+	}
+
+	if p.Bio != nil {
+		bio := strings.TrimSpace(stripControl(*p.Bio))
+		if utf8.RuneCountInString(bio) > MAX_BIO_LENGTH {
+			return params, errInvalidParameter("bio", fmt.Sprintf("Bio must be at most %d characters", MAX_BIO_LENGTH))
+		}
+		params.Bio = optionalString(bio)
+	}
+
+	if p.AvatarURL != nil {
+		avatar := strings.TrimSpace(*p.AvatarURL)
+		if avatar != "" && !validAvatarURL(avatar) {
+			return params, errInvalidParameter("avatar_url", "Avatar URL must be an absolute http or https URL")
+		}
+		params.AvatarUrl = optionalString(avatar)
+	}
+
+	return params, nil
+}
+
+func validAvatarURL(s string) bool {
+	if len(s) > MAX_AVATAR_URL_LENGTH {
+		return false
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// optionalString stores empty strings as NULL.
+func optionalString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
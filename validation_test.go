@@ -0,0 +1,143 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/SzymonJaroslawski/chirpy/internal/database"
+	"github.com/SzymonJaroslawski/chirpy/internal/filter"
+	"github.com/google/uuid"
+)
+
+func TestValidateChirp(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        filter.Mode
+		body        string
+		wantBody    string
+		wantFlagged bool
+		wantCode    string
+	}{
+		{name: "Plain", body: "Hello, world!", wantBody: "Hello, world!"},
+		{name: "Trimmed", body: "  Hello  \n", wantBody: "Hello"},
+		{name: "Empty", body: "", wantCode: CODE_EMPTY_CHIRP},
+		{name: "Whitespace only", body: " \t\n ", wantCode: CODE_EMPTY_CHIRP},
+		{name: "Control characters only", body: "\x00\x1b\x7f", wantCode: CODE_EMPTY_CHIRP},
+		{name: "Control characters stripped", body: "Hel\x00lo\x1b[31m\tworld\r\nbye", wantBody: "Hello[31m world\nbye"},
+		{name: "Bidi controls stripped", body: "\u202eHello\u202c \u2066world\u2069\u200f", wantBody: "Hello world"},
+		{name: "Zero width characters stripped", body: "\ufeffHel\u200blo\u200c wo\u2060rld", wantBody: "Hello world"},
+		{name: "Zero width joiner kept", body: "👩\u200d💻", wantBody: "👩\u200d💻"},
+		{name: "Invalid UTF-8 replaced", body: "Hi \xff", wantBody: "Hi �"},
+		{name: "Max length", body: strings.Repeat("a", 140), wantBody: strings.Repeat("a", 140)},
+		{name: "Too long", body: strings.Repeat("a", 141), wantCode: CODE_CHIRP_TOO_LONG},
+		{name: "Emoji counted as characters", body: strings.Repeat("🐦", 140), wantBody: strings.Repeat("🐦", 140)},
+		{name: "Too many emoji", body: strings.Repeat("🐦", 141), wantCode: CODE_CHIRP_TOO_LONG},
+		{name: "Stripped characters don't count", body: strings.Repeat("a", 140) + "\x00\x00", wantBody: strings.Repeat("a", 140)},
+		{name: "Masked", mode: filter.ModeMask, body: "What a kerfuffle!", wantBody: "What a *********!"},
+		{name: "Hidden by a zero width space", mode: filter.ModeMask, body: "What a ke\u200brfuffle!", wantBody: "What a *********!"},
+		{name: "Rejected", mode: filter.ModeReject, body: "What a kerfuffle!", wantCode: CODE_PROFANITY},
+		{name: "Flagged", mode: filter.ModeFlag, body: "What a kerfuffle!", wantBody: "What a kerfuffle!", wantFlagged: true},
+		{name: "Clean chirp in reject mode", mode: filter.ModeReject, body: "What a day", wantBody: "What a day"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestAPIConfig()
+			if tt.mode != "" {
+				cfg.profanityMode = tt.mode
+			}
+
+			got, err := cfg.validateChirp(tt.body)
+			if tt.wantCode != "" {
+				var apiErr *apiError
+				if !errors.As(err, &apiErr) || apiErr.Code != tt.wantCode {
+					t.Fatalf("validateChirp() error = %v, want code %q", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateChirp() error = %v", err)
+			}
+			if got.Body != tt.wantBody {
+				t.Errorf("validateChirp().Body = %q, want %q", got.Body, tt.wantBody)
+			}
+			if got.Flagged != tt.wantFlagged {
+				t.Errorf("validateChirp().Flagged = %v, want %v", got.Flagged, tt.wantFlagged)
+			}
+		})
+	}
+}
+
+func TestProfileUpdateApply(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+	current := database.User{
+		ID:          uuid.New(),
+		Handle:      sql.NullString{String: "old_handle", Valid: true},
+		DisplayName: sql.NullString{String: "Old Name", Valid: true},
+	}
+
+	tests := []struct {
+		name      string
+		update    profileUpdate
+		want      database.UpdateUserProfileParams
+		wantParam string
+	}{
+		{
+			name:   "Nothing changed",
+			update: profileUpdate{},
+			want:   database.UpdateUserProfileParams{ID: current.ID, Handle: current.Handle, DisplayName: current.DisplayName},
+		},
+		{
+			name:   "Set fields",
+			update: profileUpdate{Handle: ptr("@new_handle"), Bio: ptr("  Hi\x00 there \n"), AvatarURL: ptr("https://example.com/a.png")},
+			want: database.UpdateUserProfileParams{
+				ID:          current.ID,
+				Handle:      sql.NullString{String: "new_handle", Valid: true},
+				DisplayName: current.DisplayName,
+				Bio:         sql.NullString{String: "Hi there", Valid: true},
+				AvatarUrl:   sql.NullString{String: "https://example.com/a.png", Valid: true},
+			},
+		},
+		{
+			name:   "Clear fields",
+			update: profileUpdate{Handle: ptr(""), DisplayName: ptr("  ")},
+			want:   database.UpdateUserProfileParams{ID: current.ID},
+		},
+		{
+			name:   "Display name on one line",
+			update: profileUpdate{DisplayName: ptr(" Jane\n\tDoe ")},
+			want: database.UpdateUserProfileParams{
+				ID:          current.ID,
+				Handle:      current.Handle,
+				DisplayName: sql.NullString{String: "Jane Doe", Valid: true},
+			},
+		},
+		{name: "Handle with spaces", update: profileUpdate{Handle: ptr("jane doe")}, wantParam: "handle"},
+		{name: "Handle too long", update: profileUpdate{Handle: ptr(strings.Repeat("a", 16))}, wantParam: "handle"},
+		{name: "Display name too long", update: profileUpdate{DisplayName: ptr(strings.Repeat("a", 51))}, wantParam: "display_name"},
+		{name: "Bio too long", update: profileUpdate{Bio: ptr(strings.Repeat("🐦", 161))}, wantParam: "bio"},
+		{name: "Avatar not a URL", update: profileUpdate{AvatarURL: ptr("me.png")}, wantParam: "avatar_url"},
+		{name: "Avatar with other scheme", update: profileUpdate{AvatarURL: ptr("javascript:alert(1)")}, wantParam: "avatar_url"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.update.apply(current)
+			if tt.wantParam != "" {
+				var apiErr *apiError
+				if !errors.As(err, &apiErr) || apiErr.Details["parameter"] != tt.wantParam {
+					t.Fatalf("apply() error = %v, want invalid %q", err, tt.wantParam)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("apply() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("apply() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}